@@ -0,0 +1,233 @@
+package web_responders
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Radiobox/web_request_readers"
+	"github.com/stretchr/goweb/context"
+	"github.com/stretchr/objx"
+	"net/http"
+	"strings"
+)
+
+// MaxBatchOperations limits the number of operations in a single
+// batch request.  A value of zero or less means that there is no
+// limit.
+var MaxBatchOperations = 100
+
+// A BatchOperation is a single operation in a batch request.  The
+// request body of a batch is a list of operations, e.g.:
+//
+//	[
+//	    {"id": "a", "method": "PATCH", "path": "/songs/1", "body": {"title": "New"}},
+//	    {"id": "b", "method": "DELETE", "path": "/songs/2"}
+//	]
+//
+// The id is chosen by the client, and is included in the result of
+// the operation so that the client can match them up.
+type BatchOperation struct {
+	Id     string
+	Method string
+	Path   string
+	Body   objx.Map
+}
+
+// A BatchHandler performs a single operation of a batch request,
+// returning the status, notifications and data that it would
+// otherwise pass to Respond.  The ctx is the context of the whole
+// batch request, so handlers should read their input from the
+// operation, not from ctx.
+type BatchHandler func(ctx context.Context, operation *BatchOperation) (status int, notifications MessageMap, data interface{})
+
+// A BatchResult is the result of a single operation of a batch
+// request.
+type BatchResult struct {
+	Id            string      `response:"id,omitempty"`
+	Code          int         `response:"code"`
+	Location      URL         `response:"location,omitempty"`
+	Notifications MessageMap  `response:"notifications"`
+	Response      interface{} `response:"response"`
+}
+
+// RespondBatch performs a batch response.  It reads a list of
+// BatchOperations from the request body, performs each one with
+// handler, and responds with a list of BatchResults, in the same
+// order as the operations.
+//
+// The response values of the results are created with
+// CreateResponseWithOptions, using any "joins" in the body of each
+// operation (either an object or a JSON string, the same as the joins
+// query parameter).  The joins and fields query parameters of the
+// batch request are not applied to the results.  If a response value
+// can't be created, the result will have the status of the
+// ResponseError and its message instead.
+//
+// The status of the whole response is 200 OK if every operation
+// succeeded (i.e. had a 2xx status).  Otherwise, it is the status
+// shared by every operation, or 207 Multi-Status if their statuses
+// are mixed.
+func RespondBatch(ctx context.Context, handler BatchHandler, useFullDomain ...bool) error {
+	notifications := NewMessageMap()
+	operations, err := parseBatch(ctx)
+	if err != nil {
+		notifications.AddErrorMessage(err.Error())
+		return Respond(ctx, http.StatusBadRequest, notifications, nil, useFullDomain...)
+	}
+	if MaxBatchOperations > 0 && len(operations) > MaxBatchOperations {
+		notifications.AddErrorMessage(fmt.Sprintf("Batch requests may contain at most %d operations", MaxBatchOperations))
+		return Respond(ctx, http.StatusRequestEntityTooLarge, notifications, nil, useFullDomain...)
+	}
+
+	domain := ""
+	if len(useFullDomain) > 0 && useFullDomain[0] {
+		domain = RequestDomain(ctx.HttpRequest())
+	}
+	locale := responseLocale(ctx)
+
+	results := make([]*BatchResult, 0, len(operations))
+	for _, operation := range operations {
+		status, opNotifications, data := handler(ctx, operation)
+		results = append(results, batchResult(operation, status, opNotifications, data, domain, locale))
+	}
+
+	// The response of each result has already been created, using
+	// the joins of its operation.  The joins and fields of the batch
+	// request itself are meant for the operations, so they must not
+	// be applied to the results again (e.g. fields=title would
+	// remove the code and response of every result).
+	ctx.CodecOptions().MergeHere(objx.Map{
+		"joins":  "",
+		"fields": "",
+	})
+	return Respond(ctx, batchStatus(results), notifications, results, useFullDomain...)
+}
+
+// batchResult creates the result of a single operation.
+func batchResult(operation *BatchOperation, status int, notifications MessageMap, data interface{}, domain, locale string) *BatchResult {
+	if notifications == nil {
+		notifications = NewMessageMap()
+	}
+	result := &BatchResult{
+		Id:            operation.Id,
+		Code:          status,
+		Location:      URL(ResponseLocation(status, data)),
+		Notifications: notifications,
+	}
+
+	joins, err := batchJoins(operation)
+	if err == nil {
+		result.Response, err = CreateResponseWithOptions(data,
+			WithJoins(joins),
+			WithDomain(domain),
+			WithLocale(locale),
+		)
+	}
+	if err != nil {
+		result.fail(err)
+	}
+	result.Notifications = notifications.localized(locale)
+	return result
+}
+
+// batchJoins loads the joins options from the body of operation,
+// which may be an object or a JSON string.
+func batchJoins(operation *BatchOperation) (objx.Map, error) {
+	switch joins := operation.Body.Get("joins").Data().(type) {
+	case nil:
+		return nil, nil
+	case objx.Map:
+		return joins, nil
+	case map[string]interface{}:
+		return objx.New(joins), nil
+	case string:
+		if joins == "" {
+			return nil, nil
+		}
+		joinsMap, err := objx.FromJSON(joins)
+		if err != nil {
+			return nil, &OptionError{Message: "Could not load joins: " + err.Error()}
+		}
+		return joinsMap, nil
+	}
+	return nil, &OptionError{Message: "joins must be an object or a JSON string"}
+}
+
+// fail replaces the result with an error result.
+func (result *BatchResult) fail(err error) {
+	result.Code = http.StatusInternalServerError
+	if responseErr, ok := err.(ResponseError); ok {
+		result.Code = responseErr.ResponseStatus()
+	}
+	result.Location = ""
+	result.Response = nil
+	result.Notifications.AddErrorMessage(err.Error())
+}
+
+// batchStatus returns the status of a whole batch response.
+func batchStatus(results []*BatchResult) int {
+	if len(results) == 0 {
+		return http.StatusOK
+	}
+	succeeded := true
+	shared := results[0].Code
+	for _, result := range results {
+		if result.Code < 200 || result.Code >= 300 {
+			succeeded = false
+		}
+		if result.Code != shared {
+			shared = 0
+		}
+	}
+	switch {
+	case succeeded:
+		return http.StatusOK
+	case shared != 0:
+		return shared
+	}
+	return http.StatusMultiStatus
+}
+
+// parseBatch reads the operations of a batch request from the
+// request body.
+func parseBatch(ctx context.Context) ([]*BatchOperation, error) {
+	body, err := web_request_readers.ParseBody(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := body.([]interface{})
+	if !ok {
+		return nil, errors.New("A batch request must be a list of operations")
+	}
+	operations := make([]*BatchOperation, 0, len(list))
+	for index, element := range list {
+		var params objx.Map
+		switch src := element.(type) {
+		case objx.Map:
+			params = src
+		case map[string]interface{}:
+			params = objx.New(src)
+		default:
+			return nil, fmt.Errorf("Operation %d of the batch is not an object", index)
+		}
+		operation := &BatchOperation{
+			Method: strings.ToUpper(params.Get("method").Str()),
+			Path:   params.Get("path").Str(),
+		}
+		if id := params.Get("id").Data(); id != nil {
+			operation.Id = fmt.Sprint(id)
+		}
+		if operation.Method == "" || operation.Path == "" {
+			return nil, fmt.Errorf("Operation %d of the batch needs a method and a path", index)
+		}
+		switch src := params.Get("body").Data().(type) {
+		case objx.Map:
+			operation.Body = src
+		case map[string]interface{}:
+			operation.Body = objx.New(src)
+		default:
+			operation.Body = objx.Map{}
+		}
+		operations = append(operations, operation)
+	}
+	return operations, nil
+}
@@ -0,0 +1,88 @@
+package web_responders
+
+import (
+	"github.com/stretchr/goweb"
+	"github.com/stretchr/goweb/context"
+	"github.com/stretchr/goweb/webcontext"
+	"github.com/stretchr/objx"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatchStatus(t *testing.T) {
+	results := func(codes ...int) []*BatchResult {
+		list := make([]*BatchResult, 0, len(codes))
+		for _, code := range codes {
+			list = append(list, &BatchResult{Code: code})
+		}
+		return list
+	}
+	assert.Equal(t, http.StatusOK, batchStatus(results()))
+	assert.Equal(t, http.StatusOK, batchStatus(results(http.StatusOK, http.StatusCreated)))
+	assert.Equal(t, http.StatusNotFound, batchStatus(results(http.StatusNotFound, http.StatusNotFound)))
+	assert.Equal(t, http.StatusMultiStatus, batchStatus(results(http.StatusOK, http.StatusNotFound)))
+}
+
+func TestBatchResult(t *testing.T) {
+	operation := &BatchOperation{Id: "a", Method: "PATCH", Path: "/nodes/1", Body: objx.Map{}}
+	node := &testLocatedNode{Name: "1"}
+	result := batchResult(operation, http.StatusOK, nil, node, "http://example.com", "en")
+	assert.Equal(t, "a", result.Id)
+	assert.Equal(t, http.StatusOK, result.Code)
+	assert.Equal(t, URL("/nodes/1"), result.Location)
+	assert.Equal(t, "1", result.Response.(objx.Map)["name"])
+
+	operation.Body = objx.Map{"joins": "{not json"}
+	result = batchResult(operation, http.StatusOK, nil, node, "", "en")
+	assert.Equal(t, http.StatusBadRequest, result.Code)
+	assert.Nil(t, result.Response)
+	assert.Equal(t, 1, result.Notifications.NumErrors())
+
+	// Joins may also be an object, which has to be used rather than
+	// silently ignored.
+	operation.Body = objx.Map{"joins": map[string]interface{}{"parent": 5}}
+	result = batchResult(operation, http.StatusOK, nil, node, "", "en")
+	assert.Equal(t, http.StatusBadRequest, result.Code)
+
+	operation.Body = objx.Map{"joins": map[string]interface{}{"parent": map[string]interface{}{}}}
+	result = batchResult(operation, http.StatusOK, nil, node, "", "en")
+	assert.Equal(t, http.StatusOK, result.Code)
+
+	operation.Body = objx.Map{"joins": 5}
+	result = batchResult(operation, http.StatusOK, nil, node, "", "en")
+	assert.Equal(t, http.StatusBadRequest, result.Code)
+
+	notifications := NewMessageMap()
+	notifications.AddErrorMessage(Localize(MessageMissingInput))
+	response, err := CreateResponseWithOptions(&BatchResult{Code: http.StatusBadRequest, Notifications: notifications})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(response.(objx.Map)["notifications"].(map[string]interface{})))
+}
+
+func TestRespondBatchIgnoresRequestFields(t *testing.T) {
+	body := `[{"id": "a", "method": "GET", "path": "/nodes/1"}]`
+	request, _ := http.NewRequest("POST", `http://example.com/batch?fields=title&joins={"parent":{}}`, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	ctx := webcontext.NewWebContext(recorder, request, goweb.CodecService)
+
+	var operations []*BatchOperation
+	err := RespondBatch(ctx, func(ctx context.Context, operation *BatchOperation) (int, MessageMap, interface{}) {
+		operations = append(operations, operation)
+		return http.StatusOK, nil, &testLocatedNode{Name: "1"}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	if assert.Equal(t, 1, len(operations)) {
+		assert.Equal(t, "/nodes/1", operations[0].Path)
+	}
+
+	// The codecs would otherwise apply fields=title to the results,
+	// leaving nothing but an empty object for each of them.
+	options := ctx.CodecOptions()
+	assert.Equal(t, "", options["fields"])
+	assert.Equal(t, "", options["joins"])
+}
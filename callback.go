@@ -0,0 +1,161 @@
+package web_responders
+
+import (
+	"github.com/stretchr/goweb/context"
+	"github.com/stretchr/objx"
+	"net/http"
+	"strings"
+)
+
+const (
+	// CallbackOption is the codec option that holds the name of the
+	// callback for callback (JSONP) responses.
+	CallbackOption = "client_callback"
+
+	// JavascriptMimeType is the content type of callback responses.
+	JavascriptMimeType = "application/javascript"
+
+	// maxCallbackLength limits the length of callback names.
+	maxCallbackLength = 128
+)
+
+// reservedWords are the javascript reserved words, which can't be
+// used as callback names.
+var reservedWords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true,
+	"const": true, "continue": true, "debugger": true, "default": true,
+	"delete": true, "do": true, "else": true, "enum": true,
+	"export": true, "extends": true, "false": true, "finally": true,
+	"for": true, "function": true, "if": true, "implements": true,
+	"import": true, "in": true, "instanceof": true, "interface": true,
+	"let": true, "new": true, "null": true, "package": true,
+	"private": true, "protected": true, "public": true, "return": true,
+	"static": true, "super": true, "switch": true, "this": true,
+	"throw": true, "true": true, "try": true, "typeof": true,
+	"var": true, "void": true, "while": true, "with": true,
+	"yield": true,
+}
+
+// ValidCallback returns whether or not callback is safe to use as the
+// name of the function in a callback (JSONP) response.  Only
+// javascript identifiers and dot separated paths of identifiers (e.g.
+// "player.onData") are allowed, since anything else could be used to
+// inject script into the response.
+func ValidCallback(callback string) bool {
+	if callback == "" || len(callback) > maxCallbackLength {
+		return false
+	}
+	for _, name := range strings.Split(callback, ".") {
+		if name == "" || reservedWords[name] {
+			return false
+		}
+		for i, r := range name {
+			switch {
+			case r == '_' || r == '$':
+			case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+			case '0' <= r && r <= '9' && i > 0:
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// A callbackCodec is a codec that can write callback responses.
+type callbackCodec interface {
+	Marshal(object interface{}, options map[string]interface{}) ([]byte, error)
+	ContentType() string
+	CanMarshalWithCallback() bool
+}
+
+// callbackAllowed returns whether or not a callback (JSONP) response
+// may be sent for the request in ctx.  Any page can load a callback
+// response with a script tag, along with the user's cookies, so
+// callbacks are only allowed for unauthenticated GET (or HEAD)
+// requests: requests without an Authorization header, and without a
+// "principal" codec option (see ResponseOptions.Principal).
+// Applications that authenticate users with cookies must set the
+// principal before calling Respond, so that callbacks are refused.
+func callbackAllowed(ctx context.Context) bool {
+	request := ctx.HttpRequest()
+	if request.Method != "GET" && request.Method != "HEAD" {
+		return false
+	}
+	return request.Header.Get("Authorization") == "" && ctx.CodecOptions()["principal"] == nil
+}
+
+// respondWithCallback writes a callback (JSONP) response.  Scripts
+// loaded by callback clients (e.g. our legacy embedded players) can't
+// read the response status, so the response is always sent as a 200
+// OK, and the real status is left in the envelope's meta.  If the
+// callback isn't valid, or callbacks aren't allowed for the request
+// (see callbackAllowed), the response is a 400 Bad Request without a
+// callback instead.
+//
+// This writes the response itself, rather than using goweb, since
+// goweb would replace the content type and status.
+func respondWithCallback(ctx context.Context, codec callbackCodec, callback string, status int, notifications MessageMap, data interface{}) error {
+	options := ctx.CodecOptions()
+	contentType := JavascriptMimeType
+	problem := ""
+	switch {
+	case !callbackAllowed(ctx):
+		problem = "Callbacks are only allowed for unauthenticated GET requests"
+	case !ValidCallback(callback):
+		problem = "Invalid callback: " + callback
+	}
+	if problem == "" {
+		options.Set(CallbackOption, callback)
+	} else {
+		options.Set(CallbackOption, "")
+		if notifications == nil {
+			notifications = NewMessageMap()
+		}
+		notifications.AddErrorMessage(problem)
+		status = http.StatusBadRequest
+		contentType = codec.ContentType()
+		data = nil
+		options.MergeHere(objx.Map{
+			"status":        status,
+			"notifications": notifications,
+		})
+	}
+
+	output, err := codec.Marshal(data, options)
+	if responseErr, ok := err.(ResponseError); ok {
+		// The same as respondWithResponseError.
+		if notifications == nil {
+			notifications = NewMessageMap()
+		}
+		notifications.AddErrorMessage(responseErr.Error())
+		status = responseErr.ResponseStatus()
+		options.MergeHere(objx.Map{
+			"status":        status,
+			"notifications": notifications,
+			"joins":         "",
+		})
+		output, err = codec.Marshal(nil, options)
+	}
+	if err != nil {
+		return err
+	}
+
+	writer := ctx.HttpResponseWriter()
+	header := writer.Header()
+	if status >= 400 {
+		header.Del("Location")
+		header.Del("Link")
+	}
+	if writesMetaHeaders(ctx) {
+		setMetaHeaders(ctx, status, notifications)
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if contentType == JavascriptMimeType {
+		status = http.StatusOK
+	}
+	writer.WriteHeader(writtenStatus(ctx, status))
+	_, err = writer.Write(output)
+	return err
+}
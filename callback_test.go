@@ -0,0 +1,98 @@
+package web_responders
+
+import (
+	"encoding/json"
+	"github.com/stretchr/goweb"
+	"github.com/stretchr/goweb/webcontext"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidCallback(t *testing.T) {
+	for _, callback := range []string{"cb", "jQuery1910_123", "$", "_private", "player.onData", "a.b.c"} {
+		assert.True(t, ValidCallback(callback), callback)
+	}
+	for _, callback := range []string{
+		"",
+		"1cb",
+		"cb()",
+		"alert(1);cb",
+		"a..b",
+		".cb",
+		"cb.",
+		"a[0]",
+		"new",
+		"player.delete",
+		"<script>",
+		"café",
+		strings.Repeat("a", maxCallbackLength+1),
+	} {
+		assert.False(t, ValidCallback(callback), callback)
+	}
+}
+
+// testCallbackCodec writes a minimal envelope, wrapped in the
+// callback when there is one.
+type testCallbackCodec struct{}
+
+func (codec testCallbackCodec) Marshal(object interface{}, options map[string]interface{}) ([]byte, error) {
+	output, err := json.Marshal(map[string]interface{}{"code": options["status"], "response": object})
+	if callback, _ := options[CallbackOption].(string); callback != "" {
+		output = []byte("/**/" + callback + "(" + string(output) + ");")
+	}
+	return output, err
+}
+
+func (codec testCallbackCodec) ContentType() string {
+	return "application/vnd.test+json"
+}
+
+func (codec testCallbackCodec) CanMarshalWithCallback() bool {
+	return true
+}
+
+func respondWithTestCallback(t *testing.T, request *http.Request, callback string, principal interface{}) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	ctx := webcontext.NewWebContext(recorder, request, goweb.CodecService)
+	if principal != nil {
+		ctx.CodecOptions().Set("principal", principal)
+	}
+	_, err := setCodecOptions(ctx, http.StatusNotFound, nil, "")
+	assert.NoError(t, err)
+	assert.NoError(t, respondWithCallback(ctx, testCallbackCodec{}, callback, http.StatusNotFound, nil, "missing"))
+	assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+	return recorder
+}
+
+func TestRespondWithCallback(t *testing.T) {
+	request, _ := http.NewRequest("GET", "http://example.com/songs/1?callback=player.onData", nil)
+	recorder := respondWithTestCallback(t, request, "player.onData", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, JavascriptMimeType, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `/**/player.onData({"code":404,"response":"missing"});`, recorder.Body.String())
+
+	request, _ = http.NewRequest("GET", "http://example.com/songs/1?callback=alert(1)", nil)
+	recorder = respondWithTestCallback(t, request, "alert(1)", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "application/vnd.test+json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `{"code":400,"response":null}`, recorder.Body.String())
+}
+
+func TestRespondWithCallbackAuthenticated(t *testing.T) {
+	post, _ := http.NewRequest("POST", "http://example.com/songs?callback=cb", nil)
+	authorized, _ := http.NewRequest("GET", "http://example.com/songs/1?callback=cb", nil)
+	authorized.Header.Set("Authorization", "Bearer token")
+	for _, request := range []*http.Request{post, authorized} {
+		recorder := respondWithTestCallback(t, request, "cb", nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, request.Method)
+		assert.NotContains(t, recorder.Body.String(), "cb(")
+	}
+
+	request, _ := http.NewRequest("GET", "http://example.com/songs/1?callback=cb", nil)
+	recorder := respondWithTestCallback(t, request, "cb", "user")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "application/vnd.test+json", recorder.Header().Get("Content-Type"))
+}
@@ -0,0 +1,28 @@
+package codecs
+
+import (
+	"github.com/Radiobox/web_responders"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMarshalWithCallback(t *testing.T) {
+	options := map[string]interface{}{
+		"status":                      http.StatusNotFound,
+		"matched_type":                defaultMimeType,
+		web_responders.CallbackOption: "player.onData",
+	}
+	response, err := new(RadioboxApiCodec).Marshal(nil, options)
+	assert.NoError(t, err)
+	output := string(response)
+	assert.True(t, strings.HasPrefix(output, "/**/player.onData({"), output)
+	assert.True(t, strings.HasSuffix(output, "});"), output)
+	assert.Contains(t, output, `"code":404`)
+	assert.Equal(t, "player.onData", options[web_responders.CallbackOption])
+
+	options[web_responders.CallbackOption] = "alert(document.cookie);cb"
+	_, err = new(RadioboxApiCodec).Marshal(nil, options)
+	assert.IsType(t, &web_responders.OptionError{}, err)
+}
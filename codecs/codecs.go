@@ -13,9 +13,10 @@ import (
 	"github.com/Radiobox/web_responders"
 	"github.com/stretchr/goweb"
 	"github.com/stretchr/objx"
-	"log"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 )
 
 const (
@@ -29,69 +30,269 @@ const (
 	relEndPattern   = `"`
 )
 
+// RadioboxApiCodec writes responses in our envelope format:
+//
+//	{
+//	    "meta": {"code": 200, "input_params": {...}, ...},
+//	    "notifications": {...},
+//	    "response": ...
+//	}
+//
+// The zero value writes the standard envelope.  Extra meta values
+// (e.g. a request id, rate limit info or the server version) can be
+// added with MetaContributors, standard meta values can be left out
+// with OmitMeta, and the top-level keys can be renamed with Keys.  A
+// customized codec should be passed to AddCodecs, and must not be
+// changed while responses are being created.
 type RadioboxApiCodec struct {
+	// MetaContributors adds a value to the meta for each key, using
+	// its MetaContributor.  Contributed values replace any standard
+	// meta value with the same key, and a nil value removes it,
+	// except for "code", which is always in the meta.  Contributors
+	// are run in the order of their keys.
+	MetaContributors map[string]MetaContributor
+
+	// OmitMeta lists the meta keys (e.g. "input_params") to leave out
+	// of the meta.  "code" can't be left out.
+	OmitMeta []string
+
+	// Keys are the top-level keys of the envelope.
+	Keys EnvelopeKeys
+}
+
+// EnvelopeKeys are the top-level keys of the envelope.  Empty keys use
+// the standard names: "meta", "notifications" and "response".
+type EnvelopeKeys struct {
+	Meta          string
+	Notifications string
+	Response      string
+}
+
+// withDefaults returns keys with the standard name in place of each
+// empty key.
+func (keys EnvelopeKeys) withDefaults() EnvelopeKeys {
+	if keys.Meta == "" {
+		keys.Meta = "meta"
+	}
+	if keys.Notifications == "" {
+		keys.Notifications = "notifications"
+	}
+	if keys.Response == "" {
+		keys.Response = "response"
+	}
+	return keys
+}
+
+// AddMetaContributor sets the MetaContributor for key.
+func (codec *RadioboxApiCodec) AddMetaContributor(key string, contributor MetaContributor) {
+	if codec.MetaContributors == nil {
+		codec.MetaContributors = make(map[string]MetaContributor)
+	}
+	codec.MetaContributors[key] = contributor
 }
 
 func (codec *RadioboxApiCodec) CreateConstructor(options map[string]interface{}) func(interface{}, interface{}) interface{} {
+	keys := codec.Keys.withDefaults()
+	contributorKeys := make([]string, 0, len(codec.MetaContributors))
+	for key := range codec.MetaContributors {
+		contributorKeys = append(contributorKeys, key)
+	}
+	sort.Strings(contributorKeys)
 	return func(object interface{}, originalObject interface{}) interface{} {
 		meta := map[string]interface{}{
 			"code":         options["status"],
 			"input_params": options["input_params"],
 		}
-		if options["status"].(int) == http.StatusOK {
-			var links map[string]string
-			if linker, ok := originalObject.(web_responders.RelatedLinker); ok {
-				links = linker.RelatedLinks()
-			} else {
-				links = map[string]string{}
-			}
-			domain := options["domain"].(string)
-			for rel, link := range links {
-				links[rel] = domain + link
+		if suppressed, _ := options[web_responders.StatusSuppressedOption].(bool); suppressed {
+			meta[web_responders.StatusSuppressedOption] = true
+		}
+		status, _ := options["status"].(int)
+		domain, _ := options["domain"].(string)
+		if location := web_responders.ResponseLocation(status, originalObject); location != "" {
+			meta["location"] = web_responders.Link{Href: location}.Absolute(domain).Href
+		}
+		switch status {
+		case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+			meta["links"] = web_responders.ResponseLinks(status, originalObject).Absolute(domain).ResponseValue()
+		}
+		for _, key := range contributorKeys {
+			if value := codec.MetaContributors[key](options, originalObject); value != nil {
+				meta[key] = value
+			} else if key != "code" {
+				delete(meta, key)
 			}
-			location := "Error: no location present"
-			if locationer, ok := originalObject.(web_responders.Locationer); ok {
-				location = domain + locationer.Location()
+		}
+		for _, key := range codec.OmitMeta {
+			if key != "code" {
+				delete(meta, key)
 			}
-			links["location"] = location
-
-			meta["location"] = location
-			meta["links"] = links
 		}
 		response := map[string]interface{}{
-			"meta":          meta,
-			"notifications": options["notifications"],
-			"response":      object,
+			keys.Meta:          meta,
+			keys.Notifications: options["notifications"],
+			keys.Response:      object,
 		}
 		return response
 	}
 }
 
-// Marshal encapsulates the passed in object with our encapsulation
-// format.
-func (codec *RadioboxApiCodec) Marshal(object interface{}, options map[string]interface{}) ([]byte, error) {
+// parseJoins loads the joins options, either from the "joins" codec
+// option or from the "joins" value of the input params.  Malformed
+// joins result in a *web_responders.OptionError.
+func parseJoins(options map[string]interface{}) (objx.Map, error) {
 	var joinsStr string
 	if joinsValue, ok := options["joins"]; ok {
-		joinsStr = joinsValue.(string)
+		if joinsStr, ok = joinsValue.(string); !ok {
+			return nil, &web_responders.OptionError{Message: "joins must be a JSON string"}
+		}
 	} else if m, ok := options["input_params"].(objx.Map); ok {
 		joinsStr = m.Get("joins").Str()
 	}
-	var joins objx.Map
-	if joinsStr != "" {
-		var err error
-		joins, err = objx.FromJSON(joinsStr)
-		if err != nil {
-			log.Print("Could not load joins options: " + err.Error())
+	if joinsStr == "" {
+		return nil, nil
+	}
+	joins, err := objx.FromJSON(joinsStr)
+	if err != nil {
+		return nil, &web_responders.OptionError{Message: "Could not load joins: " + err.Error()}
+	}
+	return joins, nil
+}
+
+// responseOptions reads the web_responders.ResponseOptions for a
+// response out of the codec options.  Along with the joins and the
+// domain, the codec options may contain "fields" (a comma separated
+// string, which may also be in the input params), "max_depth" (an int
+// or web_responders.MaxDepth), "cycle_policy" (a
+// web_responders.CyclePolicy), "profile" (a web_responders.Profile or
+// its name), "time_format", "timezone" and "duration_format" (see
+// timeOptions), "locale", "principal", and "version".
+func responseOptions(options map[string]interface{}, constructor func(interface{}, interface{}) interface{}) (*web_responders.ResponseOptions, error) {
+	joins, err := parseJoins(options)
+	if err != nil {
+		return nil, err
+	}
+	domain, _ := options["domain"].(string)
+	responseOptions := web_responders.NewResponseOptions(
+		web_responders.WithJoins(joins),
+		web_responders.WithConstructor(constructor),
+		web_responders.WithDomain(domain),
+		web_responders.WithFields(parseFields(options)...),
+	)
+	switch maxDepth := options["max_depth"].(type) {
+	case int:
+		responseOptions.MaxDepth = web_responders.MaxDepth(maxDepth)
+	case web_responders.MaxDepth:
+		responseOptions.MaxDepth = maxDepth
+	}
+	if cycles, ok := options["cycle_policy"].(web_responders.CyclePolicy); ok {
+		responseOptions.Cycles = cycles
+	}
+	switch profile := options["profile"].(type) {
+	case web_responders.Profile:
+		responseOptions.Profile = profile
+	case string:
+		if profile != "" {
+			responseOptions.Profile = web_responders.Profile(profile)
+		}
+	}
+	if err := timeOptions(responseOptions, options); err != nil {
+		return nil, err
+	}
+	responseOptions.Locale, _ = options["locale"].(string)
+	responseOptions.Principal = options["principal"]
+	responseOptions.Version, _ = options["version"].(string)
+	return responseOptions, nil
+}
+
+// timeOptions reads the time formatting options from the codec
+// options.  "time_format" and "duration_format" may be either the
+// name of a format (see web_responders.TimeFormats and
+// web_responders.DurationFormats) or the format itself, and
+// "timezone" may be either an IANA time zone name (e.g. "UTC" or
+// "America/Vancouver") or a *time.Location.  Unknown names result in
+// a *web_responders.OptionError.
+func timeOptions(responseOptions *web_responders.ResponseOptions, options map[string]interface{}) error {
+	switch format := options["time_format"].(type) {
+	case web_responders.TimeFormat:
+		responseOptions.TimeFormat = format
+	case string:
+		if format != "" {
+			timeFormat, ok := web_responders.TimeFormats[format]
+			if !ok {
+				return &web_responders.OptionError{Message: "Unknown time format: " + format}
+			}
+			responseOptions.TimeFormat = timeFormat
+		}
+	}
+	switch format := options["duration_format"].(type) {
+	case web_responders.DurationFormat:
+		responseOptions.DurationFormat = format
+	case string:
+		if format != "" {
+			durationFormat, ok := web_responders.DurationFormats[format]
+			if !ok {
+				return &web_responders.OptionError{Message: "Unknown duration format: " + format}
+			}
+			responseOptions.DurationFormat = durationFormat
+		}
+	}
+	switch timezone := options["timezone"].(type) {
+	case *time.Location:
+		responseOptions.TimeLocation = timezone
+	case string:
+		if timezone != "" {
+			location, err := time.LoadLocation(timezone)
+			if err != nil {
+				return &web_responders.OptionError{Message: "Unknown time zone: " + timezone}
+			}
+			responseOptions.TimeLocation = location
 		}
 	}
+	return nil
+}
+
+// parseFields reads the sparse field selection from the options.
+func parseFields(options map[string]interface{}) []string {
+	var fieldsStr string
+	if fieldsValue, ok := options["fields"].(string); ok {
+		fieldsStr = fieldsValue
+	} else if m, ok := options["input_params"].(objx.Map); ok {
+		fieldsStr = m.Get("fields").Str()
+	}
+	if fieldsStr == "" {
+		return nil
+	}
+	fields := strings.Split(fieldsStr, ",")
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
+	}
+	return fields
+}
+
+// createResponse creates the response value for object, using the
+// ResponseOptions from the codec options.
+func createResponse(object interface{}, options map[string]interface{}, constructor func(interface{}, interface{}) interface{}) (interface{}, error) {
+	responseOptions, err := responseOptions(options, constructor)
+	if err != nil {
+		return nil, err
+	}
+	return responseOptions.CreateResponse(object)
+}
+
+// Marshal encapsulates the passed in object with our encapsulation
+// format.
+func (codec *RadioboxApiCodec) Marshal(object interface{}, options map[string]interface{}) ([]byte, error) {
 	constructor := codec.CreateConstructor(options)
-	domain := options["domain"].(string)
-	responseObject := web_responders.CreateResponse(object, joins, constructor, domain)
+	responseObject, err := createResponse(object, options, constructor)
+	if err != nil {
+		return nil, err
+	}
 	response := constructor(responseObject, object)
 
+	callback, _ := options[web_responders.CallbackOption].(string)
 	matchedType, ok := options["matched_type"].(string)
 	var baseType string
-	if ok && strings.ContainsRune(matchedType, '+') {
+	if ok && callback == "" && strings.ContainsRune(matchedType, '+') {
 		baseType = typeCategory + "/" + matchedType[len(codec.ContentType())+1:]
 	} else {
 		baseType = defaultBaseType
@@ -100,8 +301,39 @@ func (codec *RadioboxApiCodec) Marshal(object interface{}, options map[string]in
 	if err != nil {
 		return nil, err
 	}
+	if callback == "" {
+		return baseCodec.Marshal(response, options)
+	}
+	return marshalWithCallback(baseCodec.Marshal, response, options, callback)
+}
 
-	return baseCodec.Marshal(response, options)
+// marshalWithCallback marshals response as JSON (using marshal)
+// wrapped in a call to callback, for callback (JSONP) responses.
+// Callbacks that aren't web_responders.ValidCallback names result in
+// a *web_responders.OptionError.
+func marshalWithCallback(marshal func(interface{}, map[string]interface{}) ([]byte, error), response interface{}, options map[string]interface{}, callback string) ([]byte, error) {
+	if !web_responders.ValidCallback(callback) {
+		return nil, &web_responders.OptionError{Message: "Invalid callback: " + callback}
+	}
+
+	// The base codec must not add its own callback.
+	baseOptions := make(map[string]interface{}, len(options))
+	for key, value := range options {
+		if key != web_responders.CallbackOption {
+			baseOptions[key] = value
+		}
+	}
+	body, err := marshal(response, baseOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// The leading comment stops the response from being read as
+	// anything other than javascript (e.g. by Flash).
+	output := make([]byte, 0, len(body)+len(callback)+8)
+	output = append(output, "/**/"+callback+"("...)
+	output = append(output, body...)
+	return append(output, ");"...), nil
 }
 
 // Unmarshal returns an error, because unmarshaling is currently
@@ -127,10 +359,23 @@ func (codec *RadioboxApiCodec) FileExtension() string {
 	return ".rbx"
 }
 
+// CanMarshalWithCallback returns true; callback (JSONP) responses
+// contain the JSON envelope wrapped in a call to the callback.
 func (codec *RadioboxApiCodec) CanMarshalWithCallback() bool {
 	return true
 }
 
-func AddCodecs() {
-	goweb.CodecService.AddCodec(new(RadioboxApiCodec))
+// AddCodecs adds our codecs to goweb's CodecService.  A customized
+// RadioboxApiCodec may be passed in to be used in place of the
+// default one; the RadioboxNdjsonCodec will use the same envelope.
+func AddCodecs(apiCodec ...*RadioboxApiCodec) {
+	codec := new(RadioboxApiCodec)
+	if len(apiCodec) > 0 && apiCodec[0] != nil {
+		codec = apiCodec[0]
+	}
+	goweb.CodecService.AddCodec(&RadioboxNdjsonCodec{RadioboxApiCodec: *codec})
+	goweb.CodecService.AddCodec(codec)
+	goweb.CodecService.AddCodec(new(CsvCodec))
+	goweb.CodecService.AddCodec(new(HalCodec))
+	goweb.CodecService.AddCodec(new(JsonApiCodec))
 }
@@ -0,0 +1,326 @@
+package codecs
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Radiobox/web_responders"
+	"github.com/stretchr/objx"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	csvMimeType      = "text/csv"
+	csvFileExtension = ".csv"
+
+	// csvValueColumn is the column name used for collections of
+	// values that are not objects (e.g. a slice of strings).
+	csvValueColumn = "value"
+)
+
+// CsvCodec writes responses as comma separated values, for
+// spreadsheet exports.  Each element of a collection becomes a row,
+// and the columns are named using the same keys as our JSON
+// responses (see web_responders.ResponseTag).  Nested objects are
+// flattened, with their keys joined by a dot (e.g. "artist.name");
+// any other nested values (like slices) are written as JSON.
+//
+// A "fields" option (either in the codec options or the input
+// params) may contain a comma separated list of columns to include.
+// Naming an object (e.g. "artist") includes all of its nested
+// columns.
+//
+// Text that a spreadsheet would read as a formula (i.e. text starting
+// with "=", "+", "-", "@", a tab or a carriage return) is prefixed
+// with a single quote.  An empty collection is written as just the
+// header, with the columns of the collection's element type.
+//
+// CSV has no place for our envelope, so CsvCodec implements
+// web_responders.MetaHeaderCodec, and meta values like the status
+// code are sent as headers by web_responders.Respond.
+type CsvCodec struct{}
+
+// Marshal flattens the passed in object into rows and columns.
+func (codec *CsvCodec) Marshal(object interface{}, options map[string]interface{}) ([]byte, error) {
+	responseObject, err := createResponse(object, options, unwrappedConstructor)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []interface{}
+	switch src := responseObject.(type) {
+	case []interface{}:
+		elements = src
+	case nil:
+	default:
+		elements = []interface{}{src}
+	}
+
+	rows := make([]map[string]string, 0, len(elements))
+	found := make(map[string]bool)
+	for _, element := range elements {
+		row := make(map[string]string)
+		flattenCsvValue(row, "", element)
+		for column := range row {
+			found[column] = true
+		}
+		rows = append(rows, row)
+	}
+
+	var columns []string
+	if len(rows) == 0 {
+		columns = csvTypeColumns(object, parseFields(options))
+		if len(columns) == 0 {
+			return []byte{}, nil
+		}
+	} else {
+		columns = csvColumns(object, found)
+	}
+
+	buffer := new(bytes.Buffer)
+	writer := csv.NewWriter(buffer)
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			record[i] = row[column]
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// unwrappedConstructor is used for embedded collections in formats
+// that have no envelope to wrap them in (e.g. CSV or HAL).
+func unwrappedConstructor(object interface{}, originalObject interface{}) interface{} {
+	return object
+}
+
+// flattenCsvValue adds value to row, using prefix as the column name.
+// Objects are flattened recursively.
+func flattenCsvValue(row map[string]string, prefix string, value interface{}) {
+	var object map[string]interface{}
+	switch src := value.(type) {
+	case objx.Map:
+		object = src
+	case map[string]interface{}:
+		object = src
+	}
+	if object != nil {
+		for key, subValue := range object {
+			column := key
+			if prefix != "" {
+				column = prefix + "." + key
+			}
+			flattenCsvValue(row, column, subValue)
+		}
+		return
+	}
+	if prefix == "" {
+		prefix = csvValueColumn
+	}
+	row[prefix] = csvCell(value)
+}
+
+// csvCell converts a single response value to a string.  Strings
+// that a spreadsheet would read as a formula are escaped; see
+// csvText.
+func csvCell(value interface{}) string {
+	switch src := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return csvText(src)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(src)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// csvText escapes text that spreadsheets would run as a formula (or
+// as part of one) by prefixing it with a single quote, since our
+// exports are usually opened directly in a spreadsheet.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// csvColumns returns the columns in found, ordered the same way as
+// the fields of the original object's type.  Columns that can't be
+// matched to a field are sorted and added to the end.
+func csvColumns(object interface{}, found map[string]bool) []string {
+	if creator, ok := object.(web_responders.ResponseObjectCreator); ok {
+		object = creator.ResponseObject()
+	}
+	columns := make([]string, 0, len(found))
+	used := make(map[string]bool, len(found))
+	if object != nil {
+		for _, column := range typeColumns(reflect.TypeOf(object), "", 0) {
+			if found[column] && !used[column] {
+				columns = append(columns, column)
+				used[column] = true
+			}
+		}
+	}
+	remaining := make([]string, 0, len(found)-len(columns))
+	for column := range found {
+		if !used[column] {
+			remaining = append(remaining, column)
+		}
+	}
+	sort.Strings(remaining)
+	return append(columns, remaining...)
+}
+
+// csvTypeColumns returns the columns for the original object's type,
+// limited to fields (see web_responders.ResponseOptions.Fields) when
+// it is not empty.  This is used for the header of an empty
+// collection, where there are no rows to find the columns in.  Only
+// the columns that would hold values are returned, not the objects
+// that contain them.
+func csvTypeColumns(object interface{}, fields []string) []string {
+	if creator, ok := object.(web_responders.ResponseObjectCreator); ok {
+		object = creator.ResponseObject()
+	}
+	if object == nil {
+		return nil
+	}
+	all := typeColumns(reflect.TypeOf(object), "", 0)
+	columns := make([]string, 0, len(all))
+	for i, column := range all {
+		if i+1 < len(all) && strings.HasPrefix(all[i+1], column+".") {
+			continue
+		}
+		if csvColumnSelected(column, fields) {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// csvColumnSelected returns whether or not column is included by
+// fields, using the same rules as web_responders.ResponseOptions.
+func csvColumnSelected(column string, fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, field := range fields {
+		if column == field || strings.HasPrefix(column, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// maxCsvTypeDepth limits how far typeColumns will follow nested
+// struct types, to protect against recursive types.
+const maxCsvTypeDepth = 8
+
+// typeColumns lists the possible column names for a type, in field
+// order.
+func typeColumns(t reflect.Type, prefix string, depth int) []string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || depth > maxCsvTypeDepth {
+		return nil
+	}
+	columns := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			columns = append(columns, typeColumns(field.Type, prefix, depth+1)...)
+			continue
+		}
+		if !unicode.IsUpper(rune(field.Name[0])) {
+			continue
+		}
+		name := web_responders.ResponseTag(field)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		columns = append(columns, name)
+		if !csvValueType(field.Type) {
+			columns = append(columns, typeColumns(field.Type, name, depth+1)...)
+		}
+	}
+	return columns
+}
+
+// csvValueType returns whether or not t is a struct type that has a
+// value of its own (e.g. a sql.NullString or a type with a custom
+// response value), so that its fields are not columns.
+func csvValueType(t reflect.Type) bool {
+	if t.Kind() != reflect.Ptr {
+		t = reflect.PtrTo(t)
+	}
+	for _, valueType := range csvValueTypes {
+		if t.Implements(valueType) {
+			return true
+		}
+	}
+	return false
+}
+
+// csvValueTypes are the interfaces of types that have a value of their
+// own.
+var csvValueTypes = []reflect.Type{
+	reflect.TypeOf((*driver.Valuer)(nil)).Elem(),
+	reflect.TypeOf((*web_responders.Nullable)(nil)).Elem(),
+	reflect.TypeOf((*web_responders.ResponseValueCreator)(nil)).Elem(),
+	reflect.TypeOf((*web_responders.ResponseObjectCreator)(nil)).Elem(),
+	reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+}
+
+// Unmarshal returns an error, because unmarshaling is currently
+// unsupported with this codec.
+func (codec *CsvCodec) Unmarshal(data []byte, obj interface{}) error {
+	return errors.New("Unmarshal not supported")
+}
+
+func (codec *CsvCodec) ContentType() string {
+	return csvMimeType
+}
+
+// ContentTypeSupported checks a mime type string to see if this codec
+// can support responses in that format.
+func (codec *CsvCodec) ContentTypeSupported(contentType string) bool {
+	return mediaType(contentType) == csvMimeType
+}
+
+func (codec *CsvCodec) FileExtension() string {
+	return csvFileExtension
+}
+
+func (codec *CsvCodec) CanMarshalWithCallback() bool {
+	return false
+}
+
+// WritesMetaHeaders tells web_responders.Respond to send meta values
+// as headers.
+func (codec *CsvCodec) WritesMetaHeaders() bool {
+	return true
+}
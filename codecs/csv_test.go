@@ -0,0 +1,81 @@
+package codecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+type csvTestArtist struct {
+	Name string
+}
+
+type csvTestSong struct {
+	Id       int
+	Title    string
+	Artist   csvTestArtist
+	Internal string `response:"-"`
+}
+
+func TestCsvMarshalFlattensNestedObjects(t *testing.T) {
+	options := map[string]interface{}{
+		"status": http.StatusOK,
+		"domain": "",
+	}
+	songs := []csvTestSong{
+		{1, "Intro", csvTestArtist{"The xx"}, "secret"},
+		{2, "Crystalised", csvTestArtist{"The xx"}, "secret"},
+	}
+
+	codec := new(CsvCodec)
+	output, err := codec.Marshal(songs, options)
+	assert.NoError(t, err)
+	expected := "id,title,artist.name\n" +
+		"1,Intro,The xx\n" +
+		"2,Crystalised,The xx\n"
+	assert.Equal(t, expected, string(output))
+}
+
+func TestCsvMarshalSelectsFields(t *testing.T) {
+	options := map[string]interface{}{
+		"status": http.StatusOK,
+		"domain": "",
+		"fields": "title, artist",
+	}
+	songs := []csvTestSong{{1, "Intro", csvTestArtist{"The xx"}, ""}}
+
+	codec := new(CsvCodec)
+	output, err := codec.Marshal(songs, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "title,artist.name\nIntro,The xx\n", string(output))
+}
+
+func TestCsvMarshalEscapesFormulas(t *testing.T) {
+	songs := []csvTestSong{
+		{1, "=HYPERLINK(\"http://evil.example\")", csvTestArtist{"@SUM(A1)"}, ""},
+		{-2, "+1", csvTestArtist{"-1"}, ""},
+		{3, "\tTabbed", csvTestArtist{"\rReturned"}, ""},
+	}
+	output, err := new(CsvCodec).Marshal(songs, map[string]interface{}{"status": http.StatusOK})
+	assert.NoError(t, err)
+	expected := "id,title,artist.name\n" +
+		"1,\"'=HYPERLINK(\"\"http://evil.example\"\")\",'@SUM(A1)\n" +
+		"-2,'+1,'-1\n" +
+		"3,'\tTabbed,\"'\rReturned\"\n"
+	assert.Equal(t, expected, string(output))
+}
+
+func TestCsvMarshalEmptyCollection(t *testing.T) {
+	output, err := new(CsvCodec).Marshal([]csvTestSong{}, map[string]interface{}{"status": http.StatusOK})
+	assert.NoError(t, err)
+	assert.Equal(t, "id,title,artist.name\n", string(output))
+
+	options := map[string]interface{}{"status": http.StatusOK, "fields": "title,artist"}
+	output, err = new(CsvCodec).Marshal([]*csvTestSong{}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "title,artist.name\n", string(output))
+
+	output, err = new(CsvCodec).Marshal([]interface{}{}, map[string]interface{}{"status": http.StatusOK})
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(output))
+}
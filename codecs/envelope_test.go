@@ -0,0 +1,99 @@
+package codecs
+
+import (
+	"github.com/Radiobox/web_responders"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCreateConstructorLocation(t *testing.T) {
+	song := &hypermediaSong{Id: 1}
+	options := hypermediaOptions()
+	options["status"] = http.StatusCreated
+	meta := new(RadioboxApiCodec).CreateConstructor(options)(nil, song).(map[string]interface{})["meta"].(map[string]interface{})
+	assert.Equal(t, "http://example.com/songs/1", meta["location"])
+
+	meta = new(RadioboxApiCodec).CreateConstructor(options)(nil, "no location").(map[string]interface{})["meta"].(map[string]interface{})
+	assert.NotContains(t, meta, "location")
+	assert.Equal(t, map[string]interface{}{}, meta["links"])
+
+	options["status"] = http.StatusBadRequest
+	meta = new(RadioboxApiCodec).CreateConstructor(options)(nil, song).(map[string]interface{})["meta"].(map[string]interface{})
+	assert.NotContains(t, meta, "location")
+	assert.NotContains(t, meta, "links")
+}
+
+func TestCreateConstructorStatusSuppressed(t *testing.T) {
+	options := map[string]interface{}{"status": http.StatusNotFound}
+	meta := new(RadioboxApiCodec).CreateConstructor(options)(nil, nil).(map[string]interface{})["meta"].(map[string]interface{})
+	assert.NotContains(t, meta, web_responders.StatusSuppressedOption)
+
+	options[web_responders.StatusSuppressedOption] = true
+	meta = new(RadioboxApiCodec).CreateConstructor(options)(nil, nil).(map[string]interface{})["meta"].(map[string]interface{})
+	assert.Equal(t, http.StatusNotFound, meta["code"])
+	assert.Equal(t, true, meta[web_responders.StatusSuppressedOption])
+}
+
+func TestCreateConstructorMetaContributors(t *testing.T) {
+	codec := &RadioboxApiCodec{OmitMeta: []string{"input_params"}}
+	codec.AddMetaContributor("server_version", StaticMeta("1.2.3"))
+	codec.AddMetaContributor("request_id", OptionMeta("request_id"))
+	codec.AddMetaContributor("elapsed_ms", ElapsedMeta("request_start"))
+	codec.AddMetaContributor("deprecation", OptionMeta("deprecation"))
+
+	options := map[string]interface{}{
+		"status":        http.StatusOK,
+		"input_params":  map[string]interface{}{"joins": "{}"},
+		"request_id":    "abc123",
+		"request_start": time.Now().Add(-time.Second),
+	}
+	meta := codec.CreateConstructor(options)(nil, nil).(map[string]interface{})["meta"].(map[string]interface{})
+	assert.Equal(t, http.StatusOK, meta["code"])
+	assert.Equal(t, "1.2.3", meta["server_version"])
+	assert.Equal(t, "abc123", meta["request_id"])
+	assert.True(t, meta["elapsed_ms"].(int64) >= 1000)
+	assert.NotContains(t, meta, "deprecation")
+	assert.NotContains(t, meta, "input_params")
+
+	codec.AddMetaContributor("code", StaticMeta(http.StatusTeapot))
+	meta = codec.CreateConstructor(options)(nil, nil).(map[string]interface{})["meta"].(map[string]interface{})
+	assert.Equal(t, http.StatusTeapot, meta["code"])
+
+	// The code can be replaced, but never removed.
+	codec.AddMetaContributor("code", OptionMeta("missing"))
+	codec.OmitMeta = append(codec.OmitMeta, "code")
+	meta = codec.CreateConstructor(options)(nil, nil).(map[string]interface{})["meta"].(map[string]interface{})
+	assert.Equal(t, http.StatusOK, meta["code"])
+}
+
+func TestCreateConstructorContributorOrder(t *testing.T) {
+	var order []string
+	contributor := func(key string) MetaContributor {
+		return func(options map[string]interface{}, original interface{}) interface{} {
+			order = append(order, key)
+			return key
+		}
+	}
+	codec := new(RadioboxApiCodec)
+	for _, key := range []string{"request_id", "deprecation", "server_version", "elapsed_ms"} {
+		codec.AddMetaContributor(key, contributor(key))
+	}
+	for i := 0; i < 5; i++ {
+		order = nil
+		codec.CreateConstructor(map[string]interface{}{})(nil, nil)
+		assert.Equal(t, []string{"deprecation", "elapsed_ms", "request_id", "server_version"}, order)
+	}
+}
+
+func TestCreateConstructorKeys(t *testing.T) {
+	codec := &RadioboxApiCodec{Keys: EnvelopeKeys{Meta: "_meta", Response: "data"}}
+	options := map[string]interface{}{"status": http.StatusOK}
+	envelope := codec.CreateConstructor(options)("value", nil).(map[string]interface{})
+	assert.Equal(t, "value", envelope["data"])
+	assert.Equal(t, http.StatusOK, envelope["_meta"].(map[string]interface{})["code"])
+	assert.Contains(t, envelope, "notifications")
+	assert.NotContains(t, envelope, "meta")
+	assert.NotContains(t, envelope, "response")
+}
@@ -0,0 +1,178 @@
+package codecs
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Radiobox/web_responders"
+)
+
+const (
+	halMimeType      = "application/hal+json"
+	halFileExtension = ".hal"
+
+	// halItemsRel is the rel used to embed the elements of a
+	// collection response.
+	halItemsRel = "items"
+)
+
+// HalCodec writes responses in the HAL format
+// (https://tools.ietf.org/html/draft-kelly-json-hal), for clients
+// that would rather use a standard hypermedia format than our
+// envelope.
+//
+// Each struct in the response is a HAL resource.  Its links (see
+// web_responders.LinksFor) are written as _links, along with a
+// "self" link if it is a web_responders.Locationer.  Any fields
+// holding other resources (usually added by joins) are moved to
+// _embedded.  A collection response is written as a resource with
+// the elements embedded as "items".
+//
+// Fields holding related resources that were not joined, which are
+// usually written as their location, are moved to _links instead.
+// Error responses are written as a resource with the status code and
+// the error and input messages from the notifications.
+//
+// HAL has no place for our envelope's meta, so HalCodec implements
+// web_responders.MetaHeaderCodec, and meta values like the status
+// code are sent as headers by web_responders.Respond.
+type HalCodec struct{}
+
+// halResource is a resource that has been formatted for HAL.
+type halResource map[string]interface{}
+
+// Marshal formats the passed in object as HAL.
+func (codec *HalCodec) Marshal(object interface{}, options map[string]interface{}) ([]byte, error) {
+	if responseErr, ok := errorResponse(options); ok {
+		return json.Marshal(halError(responseErr))
+	}
+	domain, _ := options["domain"].(string)
+	response, err := hypermediaResponse(object, options, halFormatter(domain))
+	if err != nil {
+		return nil, err
+	}
+	if collection, ok := response.([]interface{}); ok {
+		resource := halResource{
+			"_embedded": map[string]interface{}{halItemsRel: collection},
+		}
+		if links := resourceLinks(object, domain); len(links) > 0 {
+			resource["_links"] = halLinks(links)
+		}
+		response = resource
+	}
+	return json.Marshal(response)
+}
+
+// halFormatter returns a resource formatter that converts objects to
+// HAL resources.
+func halFormatter(domain string) func(interface{}, map[string]interface{}) interface{} {
+	return func(original interface{}, object map[string]interface{}) interface{} {
+		resource := make(halResource, len(object)+2)
+		embedded := make(map[string]interface{})
+		links := resourceLinks(original, domain)
+		related := relatedLocations(original)
+		for key, value := range object {
+			if isHalEmbedded(value) {
+				embedded[key] = value
+			} else if _, ok := value.(string); ok && related[key] != "" {
+				links.Add(key, web_responders.Link{Href: related[key]}.Absolute(domain))
+			} else {
+				resource[key] = value
+			}
+		}
+		if len(links) > 0 {
+			resource["_links"] = halLinks(links)
+		}
+		if len(embedded) > 0 {
+			resource["_embedded"] = embedded
+		}
+		return resource
+	}
+}
+
+// halError returns the resource for an error response, holding its
+// status code and messages.
+func halError(responseErr *hypermediaError) halResource {
+	errs := responseErr.errors
+	if errs == nil {
+		errs = []string{}
+	}
+	resource := halResource{
+		"code":    responseErr.status,
+		"message": responseErr.title(),
+		"errors":  errs,
+	}
+	if len(responseErr.inputs) > 0 {
+		resource["input"] = responseErr.inputs
+	}
+	return resource
+}
+
+// isHalEmbedded returns whether or not value is a resource, or a
+// collection of resources, that should be embedded.
+func isHalEmbedded(value interface{}) bool {
+	switch src := value.(type) {
+	case halResource:
+		return true
+	case []interface{}:
+		if len(src) == 0 {
+			return false
+		}
+		for _, element := range src {
+			if _, ok := element.(halResource); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// halLinks converts links to the format of HAL's _links, where each
+// rel has a link object, or a list of link objects if it has more
+// than one link.
+func halLinks(links web_responders.Links) map[string]interface{} {
+	value := make(map[string]interface{}, len(links))
+	for _, rel := range links.Rels() {
+		relLinks := links[rel]
+		if len(relLinks) == 1 {
+			value[rel] = relLinks[0].ResponseValue()
+			continue
+		}
+		list := make([]interface{}, 0, len(relLinks))
+		for _, link := range relLinks {
+			list = append(list, link.ResponseValue())
+		}
+		value[rel] = list
+	}
+	return value
+}
+
+// Unmarshal returns an error, because unmarshaling is currently
+// unsupported with this codec.
+func (codec *HalCodec) Unmarshal(data []byte, obj interface{}) error {
+	return errors.New("Unmarshal not supported")
+}
+
+func (codec *HalCodec) ContentType() string {
+	return halMimeType
+}
+
+// ContentTypeSupported checks a mime type string to see if this codec
+// can support responses in that format.
+func (codec *HalCodec) ContentTypeSupported(contentType string) bool {
+	return mediaType(contentType) == halMimeType
+}
+
+func (codec *HalCodec) FileExtension() string {
+	return halFileExtension
+}
+
+func (codec *HalCodec) CanMarshalWithCallback() bool {
+	return false
+}
+
+// WritesMetaHeaders tells web_responders.Respond to send meta values
+// as headers.
+func (codec *HalCodec) WritesMetaHeaders() bool {
+	return true
+}
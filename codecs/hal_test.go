@@ -0,0 +1,144 @@
+package codecs
+
+import (
+	"encoding/json"
+	"github.com/Radiobox/web_responders"
+	"github.com/stretchr/objx"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+type hypermediaArtist struct {
+	Id   int
+	Name string
+}
+
+func (artist *hypermediaArtist) Location() string {
+	return "/artists/" + strconv.Itoa(artist.Id)
+}
+
+type hypermediaSong struct {
+	Id     int
+	Title  string
+	Artist *hypermediaArtist
+}
+
+func (song *hypermediaSong) Location() string {
+	return "/songs/" + strconv.Itoa(song.Id)
+}
+
+func (song *hypermediaSong) HypermediaLinks() web_responders.Links {
+	return web_responders.Links{
+		"play": {{Href: "/songs/1/plays", Method: "POST"}},
+	}
+}
+
+// hypermediaLabel is written as its location unless it is joined.
+type hypermediaLabel struct {
+	Id   int
+	Name string
+}
+
+func (label *hypermediaLabel) Location() string {
+	return "/labels/" + strconv.Itoa(label.Id)
+}
+
+func (label *hypermediaLabel) ResponseValue(options objx.Map) interface{} {
+	return label.Location()
+}
+
+type hypermediaAlbum struct {
+	Id    int
+	Title string
+	Label *hypermediaLabel
+}
+
+func hypermediaErrorOptions() map[string]interface{} {
+	notifications := web_responders.NewMessageMap()
+	notifications.AddErrorMessage("Album not saved")
+	notifications.SetInputMessage("title", "Title is required")
+	options := hypermediaOptions()
+	options["status"] = http.StatusBadRequest
+	options["notifications"] = notifications
+	return options
+}
+
+func hypermediaOptions() map[string]interface{} {
+	return map[string]interface{}{
+		"status":        http.StatusOK,
+		"input_params":  map[string]interface{}{},
+		"notifications": map[string]interface{}{},
+		"domain":        "http://example.com",
+	}
+}
+
+func TestHalMarshalResource(t *testing.T) {
+	song := &hypermediaSong{Id: 1, Title: "Song", Artist: &hypermediaArtist{Id: 2, Name: "Artist"}}
+	output, err := new(HalCodec).Marshal(song, hypermediaOptions())
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output, &response))
+	assert.Equal(t, "Song", response["title"])
+	assert.NotContains(t, response, "artist")
+	assert.Equal(t, map[string]interface{}{
+		"self": map[string]interface{}{"href": "http://example.com/songs/1"},
+		"play": map[string]interface{}{"href": "http://example.com/songs/1/plays", "method": "POST"},
+	}, response["_links"])
+
+	embedded := response["_embedded"].(map[string]interface{})
+	artist := embedded["artist"].(map[string]interface{})
+	assert.Equal(t, "Artist", artist["name"])
+	assert.Equal(t, map[string]interface{}{
+		"self": map[string]interface{}{"href": "http://example.com/artists/2"},
+	}, artist["_links"])
+}
+
+func TestHalMarshalCollection(t *testing.T) {
+	songs := []*hypermediaSong{{Id: 1, Title: "First"}, {Id: 2, Title: "Second"}}
+	output, err := new(HalCodec).Marshal(songs, hypermediaOptions())
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output, &response))
+	items := response["_embedded"].(map[string]interface{})["items"].([]interface{})
+	if assert.Equal(t, 2, len(items)) {
+		assert.Equal(t, "Second", items[1].(map[string]interface{})["title"])
+	}
+}
+
+func TestHalMarshalUnjoinedRelationship(t *testing.T) {
+	album := &hypermediaAlbum{Id: 1, Title: "Album", Label: &hypermediaLabel{Id: 3}}
+	output, err := new(HalCodec).Marshal(album, hypermediaOptions())
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output, &response))
+	assert.NotContains(t, response, "label")
+	assert.NotContains(t, response, "_embedded")
+	assert.Equal(t, map[string]interface{}{
+		"label": map[string]interface{}{"href": "http://example.com/labels/3"},
+	}, response["_links"])
+}
+
+func TestHalMarshalError(t *testing.T) {
+	output, err := new(HalCodec).Marshal(nil, hypermediaErrorOptions())
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output, &response))
+	assert.Equal(t, map[string]interface{}{
+		"code":    float64(http.StatusBadRequest),
+		"message": "Bad Request",
+		"errors":  []interface{}{"Album not saved"},
+		"input":   map[string]interface{}{"title": "Title is required"},
+	}, response)
+}
+
+func TestHalContentTypeSupported(t *testing.T) {
+	codec := new(HalCodec)
+	assert.True(t, codec.ContentTypeSupported("application/hal+json; charset=utf-8"))
+	assert.False(t, codec.ContentTypeSupported("application/json"))
+}
@@ -0,0 +1,125 @@
+package codecs
+
+import (
+	"github.com/Radiobox/web_responders"
+	"github.com/stretchr/objx"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// mediaType returns contentType without any parameters, e.g.
+// "application/hal+json; charset=utf-8" becomes
+// "application/hal+json".
+func mediaType(contentType string) string {
+	if index := strings.IndexRune(contentType, ';'); index != -1 {
+		contentType = contentType[:index]
+	}
+	return strings.TrimSpace(contentType)
+}
+
+// resourceLinks returns the links for original, with a "self" link
+// if it is a web_responders.Locationer.  Links relative to the server
+// root path will have domain prepended to them.
+func resourceLinks(original interface{}, domain string) web_responders.Links {
+	links := web_responders.LinksFor(original)
+	if locationer, ok := original.(web_responders.Locationer); ok {
+		links["self"] = []web_responders.Link{{Href: locationer.Location()}}
+	}
+	return links.Absolute(domain)
+}
+
+// hypermediaResponse creates the response value for object, formatting
+// each resource (including object itself, if it is a struct) with
+// formatter.
+func hypermediaResponse(object interface{}, options map[string]interface{}, formatter func(interface{}, map[string]interface{}) interface{}) (interface{}, error) {
+	responseOptions, err := responseOptions(options, unwrappedConstructor)
+	if err != nil {
+		return nil, err
+	}
+	responseOptions.ResourceFormatter = formatter
+	response, err := responseOptions.CreateResponse(object)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := response.(objx.Map); ok {
+		return formatter(object, m), nil
+	}
+	return response, nil
+}
+
+// A hypermediaError holds the messages for the body of an error
+// response.  Hypermedia formats send the envelope's meta as headers
+// (see web_responders.MetaHeaderCodec), so without these the body of
+// an error response would be empty.
+type hypermediaError struct {
+	status int
+	errors []string
+	inputs map[string]string
+}
+
+// title returns the standard text for the status of the error.
+func (err *hypermediaError) title() string {
+	return http.StatusText(err.status)
+}
+
+// inputNames returns the names of the inputs with messages, in order.
+func (err *hypermediaError) inputNames() []string {
+	names := make([]string, 0, len(err.inputs))
+	for name := range err.inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errorResponse returns the messages for the body of a response with
+// options, if its status is an error status.
+func errorResponse(options map[string]interface{}) (*hypermediaError, bool) {
+	status, _ := options["status"].(int)
+	if status < 400 {
+		return nil, false
+	}
+	err := &hypermediaError{status: status}
+	var notifications map[string]interface{}
+	switch src := options["notifications"].(type) {
+	case web_responders.MessageMap:
+		notifications = src
+	case map[string]interface{}:
+		notifications = src
+	}
+	err.errors, _ = notifications["err"].([]string)
+	err.inputs, _ = notifications["input"].(map[string]string)
+	return err, true
+}
+
+// relatedLocations returns the locations of the fields of original
+// that hold web_responders.Locationers, by response key.  Related
+// values that were not joined are usually written as their location
+// (see web_responders.ResponseValueCreator), so these are used to
+// turn them into links.
+func relatedLocations(original interface{}) map[string]string {
+	value := reflect.Indirect(reflect.ValueOf(original))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	locations := make(map[string]string)
+	for _, field := range reflect.VisibleFields(value.Type()) {
+		if field.Anonymous || field.PkgPath != "" {
+			continue
+		}
+		name := web_responders.ResponseTag(field)
+		if name == "-" {
+			continue
+		}
+		fieldValue, err := value.FieldByIndexErr(field.Index)
+		if err != nil || (fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil()) {
+			continue
+		}
+		if locationer, ok := fieldValue.Interface().(web_responders.Locationer); ok {
+			locations[name] = locationer.Location()
+		}
+	}
+	return locations
+}
@@ -0,0 +1,294 @@
+package codecs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Radiobox/web_responders"
+	"reflect"
+	"strconv"
+)
+
+const (
+	jsonApiMimeType      = "application/vnd.api+json"
+	jsonApiFileExtension = ".jsonapi"
+)
+
+// A JsonApiTyper returns the JSON:API type of its resources.  Types
+// that don't implement JsonApiTyper use the snake cased name of the
+// type (e.g. "play_list" for a PlayList).
+type JsonApiTyper interface {
+	JsonApiType() string
+}
+
+// JsonApiCodec writes responses in the JSON:API format
+// (http://jsonapi.org), for clients that would rather use a standard
+// hypermedia format than our envelope.
+//
+// Each struct in the response that has an "id" is a JSON:API
+// resource; its other fields are its attributes, and its links (see
+// web_responders.LinksFor) are its links, along with a "self" link
+// if it is a web_responders.Locationer.  Fields holding other
+// resources (usually added by joins) become relationships, and the
+// related resources are added to the document's "included" list.
+// Related resources that were not joined, which are usually written
+// as their location, become relationships with a "related" link.
+// Error responses have a top-level "errors" member in place of
+// "data", with an error object for each error and input message from
+// the notifications.
+//
+// JSON:API has no place for our envelope's meta, so JsonApiCodec
+// implements web_responders.MetaHeaderCodec, and meta values like the
+// status code are sent as headers by web_responders.Respond.
+type JsonApiCodec struct{}
+
+// jsonApiResource is a resource object that has been formatted for
+// JSON:API.
+type jsonApiResource map[string]interface{}
+
+// identifier returns the resource identifier object for resource.
+func (resource jsonApiResource) identifier() map[string]interface{} {
+	return map[string]interface{}{
+		"type": resource["type"],
+		"id":   resource["id"],
+	}
+}
+
+// key returns a string that is unique to resource's type and id.
+func (resource jsonApiResource) key() string {
+	return fmt.Sprintf("%s/%s", resource["type"], resource["id"])
+}
+
+// jsonApiDocument collects the included resources of a JSON:API
+// document while its primary data is being created.
+type jsonApiDocument struct {
+	domain   string
+	included []jsonApiResource
+	seen     map[string]bool
+}
+
+// Marshal formats the passed in object as a JSON:API document.
+func (codec *JsonApiCodec) Marshal(object interface{}, options map[string]interface{}) ([]byte, error) {
+	if responseErr, ok := errorResponse(options); ok {
+		return json.Marshal(map[string]interface{}{"errors": jsonApiErrors(responseErr)})
+	}
+	domain, _ := options["domain"].(string)
+	document := &jsonApiDocument{
+		domain: domain,
+		seen:   make(map[string]bool),
+	}
+	data, err := hypermediaResponse(object, options, document.format)
+	if err != nil {
+		return nil, err
+	}
+
+	response := map[string]interface{}{"data": data}
+	if collection, ok := data.([]interface{}); ok {
+		if links := jsonApiLinks(resourceLinks(object, domain)); len(links) > 0 {
+			response["links"] = links
+		}
+		document.excludePrimary(collection...)
+	} else {
+		document.excludePrimary(data)
+	}
+	if len(document.included) > 0 {
+		response["included"] = document.included
+	}
+	return json.Marshal(response)
+}
+
+// format converts object to a resource object, if it has an id.
+func (document *jsonApiDocument) format(original interface{}, object map[string]interface{}) interface{} {
+	id, ok := object["id"]
+	if !ok || id == nil {
+		return object
+	}
+	resource := jsonApiResource{
+		"type": jsonApiType(original),
+		"id":   fmt.Sprint(id),
+	}
+	attributes := make(map[string]interface{}, len(object))
+	relationships := make(map[string]interface{})
+	related := relatedLocations(original)
+	for key, value := range object {
+		if key == "id" {
+			continue
+		}
+		if linkage, ok := document.include(value); ok {
+			relationships[key] = map[string]interface{}{"data": linkage}
+			continue
+		}
+		if _, ok := value.(string); ok && related[key] != "" {
+			href := web_responders.URL(related[key]).Absolute(document.domain)
+			relationships[key] = map[string]interface{}{
+				"links": map[string]interface{}{"related": href},
+			}
+			continue
+		}
+		attributes[key] = value
+	}
+	if len(attributes) > 0 {
+		resource["attributes"] = attributes
+	}
+	if len(relationships) > 0 {
+		resource["relationships"] = relationships
+	}
+	if links := jsonApiLinks(resourceLinks(original, document.domain)); len(links) > 0 {
+		resource["links"] = links
+	}
+	return resource
+}
+
+// include adds value to the included resources if it is a resource
+// object or a collection of resource objects, returning its resource
+// linkage.
+func (document *jsonApiDocument) include(value interface{}) (interface{}, bool) {
+	switch src := value.(type) {
+	case jsonApiResource:
+		document.add(src)
+		return src.identifier(), true
+	case []interface{}:
+		if len(src) == 0 {
+			return nil, false
+		}
+		linkage := make([]interface{}, 0, len(src))
+		for _, element := range src {
+			resource, ok := element.(jsonApiResource)
+			if !ok {
+				return nil, false
+			}
+			linkage = append(linkage, resource.identifier())
+		}
+		for _, element := range src {
+			document.add(element.(jsonApiResource))
+		}
+		return linkage, true
+	}
+	return nil, false
+}
+
+// add adds resource to the included resources, unless a resource with
+// the same type and id has already been included.
+func (document *jsonApiDocument) add(resource jsonApiResource) {
+	key := resource.key()
+	if document.seen[key] {
+		return
+	}
+	document.seen[key] = true
+	document.included = append(document.included, resource)
+}
+
+// excludePrimary removes the primary data from the included
+// resources, since a document may only contain one resource object
+// for each type and id.
+func (document *jsonApiDocument) excludePrimary(data ...interface{}) {
+	primary := make(map[string]bool, len(data))
+	for _, element := range data {
+		if resource, ok := element.(jsonApiResource); ok {
+			primary[resource.key()] = true
+		}
+	}
+	included := document.included[:0]
+	for _, resource := range document.included {
+		if !primary[resource.key()] {
+			included = append(included, resource)
+		}
+	}
+	document.included = included
+}
+
+// jsonApiErrors returns the error objects for an error response,
+// with one for each error message and input message.  If there are no
+// messages, there is a single error object for the status.
+func jsonApiErrors(responseErr *hypermediaError) []interface{} {
+	status := strconv.Itoa(responseErr.status)
+	errs := make([]interface{}, 0, len(responseErr.errors)+len(responseErr.inputs))
+	for _, message := range responseErr.errors {
+		errs = append(errs, map[string]interface{}{
+			"status": status,
+			"title":  responseErr.title(),
+			"detail": message,
+		})
+	}
+	for _, input := range responseErr.inputNames() {
+		errs = append(errs, map[string]interface{}{
+			"status": status,
+			"title":  responseErr.title(),
+			"detail": responseErr.inputs[input],
+			"source": map[string]interface{}{"parameter": input},
+		})
+	}
+	if len(errs) == 0 {
+		errs = append(errs, map[string]interface{}{
+			"status": status,
+			"title":  responseErr.title(),
+		})
+	}
+	return errs
+}
+
+// jsonApiType returns the JSON:API type of original.
+func jsonApiType(original interface{}) string {
+	if typer, ok := original.(JsonApiTyper); ok {
+		return typer.JsonApiType()
+	}
+	t := reflect.TypeOf(original)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Name() == "" {
+		return "resource"
+	}
+	return web_responders.SnakeCase(t.Name())
+}
+
+// jsonApiLinks converts links to the format of a JSON:API links
+// object.  JSON:API allows only one link per rel, so only the first
+// link for each rel is used, and templated links are left out.
+func jsonApiLinks(links web_responders.Links) map[string]interface{} {
+	value := make(map[string]interface{}, len(links))
+	for _, rel := range links.Rels() {
+		for _, link := range links[rel] {
+			if link.Templated {
+				continue
+			}
+			if link.Title == "" && link.Type == "" {
+				value[rel] = link.Href
+			} else {
+				value[rel] = link.ResponseValue()
+			}
+			break
+		}
+	}
+	return value
+}
+
+// Unmarshal returns an error, because unmarshaling is currently
+// unsupported with this codec.
+func (codec *JsonApiCodec) Unmarshal(data []byte, obj interface{}) error {
+	return errors.New("Unmarshal not supported")
+}
+
+func (codec *JsonApiCodec) ContentType() string {
+	return jsonApiMimeType
+}
+
+// ContentTypeSupported checks a mime type string to see if this codec
+// can support responses in that format.
+func (codec *JsonApiCodec) ContentTypeSupported(contentType string) bool {
+	return mediaType(contentType) == jsonApiMimeType
+}
+
+func (codec *JsonApiCodec) FileExtension() string {
+	return jsonApiFileExtension
+}
+
+func (codec *JsonApiCodec) CanMarshalWithCallback() bool {
+	return false
+}
+
+// WritesMetaHeaders tells web_responders.Respond to send meta values
+// as headers.
+func (codec *JsonApiCodec) WritesMetaHeaders() bool {
+	return true
+}
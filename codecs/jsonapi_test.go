@@ -0,0 +1,82 @@
+package codecs
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJsonApiMarshalResource(t *testing.T) {
+	song := &hypermediaSong{Id: 1, Title: "Song", Artist: &hypermediaArtist{Id: 2, Name: "Artist"}}
+	output, err := new(JsonApiCodec).Marshal(song, hypermediaOptions())
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output, &response))
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, "hypermedia_song", data["type"])
+	assert.Equal(t, "1", data["id"])
+	assert.Equal(t, map[string]interface{}{"title": "Song"}, data["attributes"])
+	assert.Equal(t, map[string]interface{}{
+		"artist": map[string]interface{}{
+			"data": map[string]interface{}{"type": "hypermedia_artist", "id": "2"},
+		},
+	}, data["relationships"])
+	assert.Equal(t, "http://example.com/songs/1", data["links"].(map[string]interface{})["self"])
+
+	included := response["included"].([]interface{})
+	if assert.Equal(t, 1, len(included)) {
+		artist := included[0].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"name": "Artist"}, artist["attributes"])
+	}
+}
+
+func TestJsonApiMarshalCollectionExcludesPrimaryData(t *testing.T) {
+	artist := &hypermediaArtist{Id: 2, Name: "Artist"}
+	songs := []*hypermediaSong{
+		{Id: 1, Title: "First", Artist: artist},
+		{Id: 2, Title: "Second", Artist: artist},
+	}
+	output, err := new(JsonApiCodec).Marshal(songs, hypermediaOptions())
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output, &response))
+	assert.Equal(t, 2, len(response["data"].([]interface{})))
+	assert.Equal(t, 1, len(response["included"].([]interface{})))
+}
+
+func TestJsonApiMarshalUnjoinedRelationship(t *testing.T) {
+	album := &hypermediaAlbum{Id: 1, Title: "Album", Label: &hypermediaLabel{Id: 3}}
+	output, err := new(JsonApiCodec).Marshal(album, hypermediaOptions())
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output, &response))
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"title": "Album"}, data["attributes"])
+	assert.Equal(t, map[string]interface{}{
+		"label": map[string]interface{}{
+			"links": map[string]interface{}{"related": "http://example.com/labels/3"},
+		},
+	}, data["relationships"])
+	assert.NotContains(t, response, "included")
+}
+
+func TestJsonApiMarshalError(t *testing.T) {
+	output, err := new(JsonApiCodec).Marshal(nil, hypermediaErrorOptions())
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(output, &response))
+	assert.NotContains(t, response, "data")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"status": "400", "title": "Bad Request", "detail": "Album not saved"},
+		map[string]interface{}{
+			"status": "400",
+			"title":  "Bad Request",
+			"detail": "Title is required",
+			"source": map[string]interface{}{"parameter": "title"},
+		},
+	}, response["errors"])
+}
@@ -0,0 +1,45 @@
+package codecs
+
+import (
+	"time"
+)
+
+// A MetaContributor returns a value for the meta of a response, given
+// the codec options and the original (unconverted) response object.
+// Returning nil leaves the value out of the meta.
+//
+// Values that only the request handler knows about (e.g. a request
+// id or rate limit info) can be added to the codec options by the
+// handler (or middleware) before calling web_responders.Respond, and
+// read from there by a contributor; see OptionMeta.
+type MetaContributor func(options map[string]interface{}, original interface{}) interface{}
+
+// StaticMeta returns a MetaContributor that always returns value,
+// e.g. the version of the server.
+func StaticMeta(value interface{}) MetaContributor {
+	return func(options map[string]interface{}, original interface{}) interface{} {
+		return value
+	}
+}
+
+// OptionMeta returns a MetaContributor that returns the codec option
+// with key, e.g. a request id or deprecation notice added by the
+// request handler.
+func OptionMeta(key string) MetaContributor {
+	return func(options map[string]interface{}, original interface{}) interface{} {
+		return options[key]
+	}
+}
+
+// ElapsedMeta returns a MetaContributor that returns the number of
+// milliseconds since the time.Time in the codec option with startKey,
+// which should be set when the request is received.
+func ElapsedMeta(startKey string) MetaContributor {
+	return func(options map[string]interface{}, original interface{}) interface{} {
+		start, ok := options[startKey].(time.Time)
+		if !ok {
+			return nil
+		}
+		return time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	}
+}
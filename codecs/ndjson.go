@@ -0,0 +1,112 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+const (
+	ndjsonSuffix        = "+ndjson"
+	ndjsonMimeType      = BasicMimeType + ndjsonSuffix
+	ndjsonGenericType   = "application/x-ndjson"
+	ndjsonFileExtension = ".ndjson"
+)
+
+// RadioboxNdjsonCodec is a variant of RadioboxApiCodec that writes
+// newline delimited JSON (also known as JSON Lines).  The first line
+// of the output is the envelope without its response (i.e. meta and
+// notifications), and every following line is a single element of
+// the response.  Responses that are not collections are written as a
+// single line after the meta line.
+//
+// This is intended for bulk sync jobs, where clients would rather
+// read one object at a time than parse one giant array.  The codec is
+// a web_responders.StreamingCodec, so web_responders.Respond writes
+// responses with Encode, one line at a time; Marshal creates the whole
+// output in memory, and is only used when the bytes are needed up
+// front (e.g. for error responses).
+type RadioboxNdjsonCodec struct {
+	RadioboxApiCodec
+}
+
+// Marshal encodes the passed in object as newline delimited JSON,
+// returning the whole output at once.  Use Encode to stream it.
+func (codec *RadioboxNdjsonCodec) Marshal(object interface{}, options map[string]interface{}) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if err := codec.Encode(buffer, object, options); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Encode writes the passed in object to w, one line at a time.  The
+// meta line is written first, and then each element of a collection
+// is created and written in turn, so the whole response is never
+// held in memory.  If w is an http.Flusher, it will be flushed after
+// every line, so that clients can begin reading elements before the
+// whole response has been generated.
+//
+// Malformed options result in an error before anything is written,
+// but an element that can't be created ends the output early.
+func (codec *RadioboxNdjsonCodec) Encode(w io.Writer, object interface{}, options map[string]interface{}) error {
+	constructor := codec.CreateConstructor(options)
+	responseOptions, err := responseOptions(options, constructor)
+	if err != nil {
+		return err
+	}
+
+	envelope, ok := constructor(nil, object).(map[string]interface{})
+	if !ok {
+		return errors.New("Envelope is not a map; cannot write meta line")
+	}
+	delete(envelope, codec.Keys.withDefaults().Response)
+
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	writeLine := func(line interface{}) error {
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	if err := writeLine(envelope); err != nil {
+		return err
+	}
+	return responseOptions.StreamResponse(object, writeLine)
+}
+
+// Unmarshal returns an error, because unmarshaling is currently
+// unsupported with this codec.
+func (codec *RadioboxNdjsonCodec) Unmarshal(data []byte, obj interface{}) error {
+	return errors.New("Unmarshal not supported")
+}
+
+func (codec *RadioboxNdjsonCodec) ContentType() string {
+	return ndjsonMimeType
+}
+
+// ContentTypeSupported checks a mime type string to see if this codec
+// can support responses in that format.  Both our vendor type (with
+// the +ndjson suffix) and the generic application/x-ndjson type are
+// supported.
+func (codec *RadioboxNdjsonCodec) ContentTypeSupported(contentType string) bool {
+	contentType = mediaType(contentType)
+	return contentType == ndjsonMimeType || contentType == ndjsonGenericType
+}
+
+func (codec *RadioboxNdjsonCodec) FileExtension() string {
+	return ndjsonFileExtension
+}
+
+// CanMarshalWithCallback returns false, since a callback can't wrap
+// multiple top level values.
+func (codec *RadioboxNdjsonCodec) CanMarshalWithCallback() bool {
+	return false
+}
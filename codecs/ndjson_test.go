@@ -0,0 +1,102 @@
+package codecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Radiobox/web_responders"
+	"github.com/stretchr/objx"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+type ndjsonTestElement struct {
+	Id   int
+	Name string
+}
+
+func TestNdjsonMarshalWritesOneElementPerLine(t *testing.T) {
+	options := map[string]interface{}{
+		"status":        http.StatusOK,
+		"input_params":  map[string]interface{}{},
+		"notifications": map[string]interface{}{},
+		"domain":        "",
+	}
+	elements := []ndjsonTestElement{{1, "first"}, {2, "second"}}
+
+	codec := new(RadioboxNdjsonCodec)
+	output, err := codec.Marshal(elements, options)
+	assert.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(output), []byte("\n"))
+	if assert.Equal(t, 3, len(lines)) {
+		meta := make(map[string]interface{})
+		assert.NoError(t, json.Unmarshal(lines[0], &meta))
+		assert.Contains(t, meta, "meta")
+		assert.NotContains(t, meta, "response")
+
+		element := make(map[string]interface{})
+		assert.NoError(t, json.Unmarshal(lines[2], &element))
+		assert.Equal(t, "second", element["name"])
+	}
+}
+
+func TestNdjsonMarshalEnvelopeKeys(t *testing.T) {
+	codec := &RadioboxNdjsonCodec{RadioboxApiCodec{Keys: EnvelopeKeys{Response: "data"}}}
+	output, err := codec.Marshal([]ndjsonTestElement{{1, "first"}}, map[string]interface{}{"status": http.StatusOK})
+	assert.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(output), []byte("\n"))
+	if assert.Equal(t, 2, len(lines)) {
+		meta := make(map[string]interface{})
+		assert.NoError(t, json.Unmarshal(lines[0], &meta))
+		assert.Contains(t, meta, "meta")
+		assert.NotContains(t, meta, "data")
+	}
+}
+
+func TestNdjsonContentTypeSupported(t *testing.T) {
+	codec := new(RadioboxNdjsonCodec)
+	assert.True(t, codec.ContentTypeSupported("application/vnd.radiobox.encapsulated+ndjson"))
+	assert.True(t, codec.ContentTypeSupported("application/x-ndjson; charset=utf-8"))
+	assert.False(t, codec.ContentTypeSupported("application/json"))
+}
+
+// ndjsonStreamLog records when elements are created and when lines
+// are written, to check that they are interleaved.
+type ndjsonStreamLog []string
+
+func (log *ndjsonStreamLog) Write(p []byte) (int, error) {
+	*log = append(*log, "line")
+	return len(p), nil
+}
+
+func (log *ndjsonStreamLog) Flush() {
+	*log = append(*log, "flush")
+}
+
+type ndjsonStreamElement struct {
+	Name string
+	log  *ndjsonStreamLog
+}
+
+func (element ndjsonStreamElement) ResponseValue(options objx.Map) interface{} {
+	*element.log = append(*element.log, "create "+element.Name)
+	return element.Name
+}
+
+func TestNdjsonEncodeStreamsElements(t *testing.T) {
+	log := new(ndjsonStreamLog)
+	elements := []ndjsonStreamElement{{"a", log}, {"b", log}}
+	err := new(RadioboxNdjsonCodec).Encode(log, elements, map[string]interface{}{"status": http.StatusOK})
+	assert.NoError(t, err)
+	assert.Equal(t, ndjsonStreamLog{
+		"line", "flush",
+		"create a", "line", "flush",
+		"create b", "line", "flush",
+	}, *log)
+}
+
+func TestNdjsonIsStreamingCodec(t *testing.T) {
+	assert.Implements(t, (*web_responders.StreamingCodec)(nil), new(RadioboxNdjsonCodec))
+}
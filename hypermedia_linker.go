@@ -0,0 +1,13 @@
+package web_responders
+
+// A HypermediaLinker returns links to values related to itself,
+// with more detail than a RelatedLinker allows.  Values that
+// implement both are treated as HypermediaLinkers.
+type HypermediaLinker interface {
+
+	// HypermediaLinks should return the links for each rel, which
+	// will be added to the Link header and to the links in the
+	// response's meta.  Hrefs relative to the server root path (i.e.
+	// starting with "/") will have the domain prepended to them.
+	HypermediaLinks() Links
+}
@@ -1,5 +1,10 @@
 package web_responders
 
+// An InputValidator is a type that can check whether or not an input
+// value can be used for it.  RespondWithInputErrors will use
+// ValidateInput in place of its own type checks.  If the returned
+// error is Translatable (e.g. a *LocalizedMessage from Localize),
+// the message will be translated to the client's locale.
 type InputValidator interface {
 	ValidateInput(interface{}) error
 }
@@ -0,0 +1,9 @@
+package web_responders
+
+// An IsZeroer is a type that knows when its value should be
+// considered empty.  Struct fields with the "omitempty" tag option
+// will be left out of a response when IsZero returns true.  This is
+// the same method used by time.Time, so zero times are left out, too.
+type IsZeroer interface {
+	IsZero() bool
+}
@@ -0,0 +1,290 @@
+package web_responders
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/goweb/context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JobsPath is the path (relative to the server root path) that job
+// status resources are served from, e.g. "/jobs/12".
+var JobsPath = "/jobs"
+
+// ErrJobNotFound is returned by a JobStore when there is no job with
+// the requested id.
+var ErrJobNotFound = errors.New("Job not found")
+
+// A JobState is the state of a Job.
+type JobState string
+
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+// A Job is a long-running operation (e.g. transcoding or an import)
+// that is performed after the response to the request that started
+// it.  The response to that request is a 202 Accepted (see
+// RespondWithJob), with a Location pointing to the job's status
+// resource, which clients can poll (see RespondWithJobStatus) until
+// the job is finished.
+type Job struct {
+	Id    string   `response:"id"`
+	State JobState `response:"state"`
+
+	// Progress is the fraction of the work that is done, from 0 to
+	// 1.
+	Progress float64 `response:"progress"`
+
+	// ResultLocation is the location of the result of the job, once
+	// it has succeeded.
+	ResultLocation URL `response:"result_location,omitempty"`
+
+	Created time.Time `response:"created"`
+	Updated time.Time `response:"updated"`
+
+	// Notifications holds the messages from the job, which are
+	// used as the notifications of the status resource.
+	Notifications MessageMap `response:"-"`
+}
+
+// Location returns the location of the job's status resource.
+func (job *Job) Location() string {
+	return JobsPath + "/" + job.Id
+}
+
+// StatusLocation returns the location of the job's status resource,
+// which is used as the Location of 202 Accepted responses.
+func (job *Job) StatusLocation() string {
+	return job.Location()
+}
+
+// HypermediaLinks returns a "result" link once the job has a result.
+func (job *Job) HypermediaLinks() Links {
+	links := Links{}
+	if job.ResultLocation != "" {
+		links.Add("result", Link{Href: string(job.ResultLocation)})
+	}
+	return links
+}
+
+// Finished returns whether or not the job is done, successfully or
+// not.
+func (job *Job) Finished() bool {
+	return job.State == JobSucceeded || job.State == JobFailed
+}
+
+// A JobStore stores jobs so that their status can be checked from any
+// request (or, for stores backed by a database, any server).
+// Implementations must be safe to use from multiple goroutines.
+type JobStore interface {
+
+	// Create stores a new job, setting its Id.
+	Create(job *Job) error
+
+	// Update stores the current state of an existing job.
+	Update(job *Job) error
+
+	// Get returns the job with id, or ErrJobNotFound.
+	Get(id string) (*Job, error)
+}
+
+// DefaultJobRetention is the Retention of new MemoryJobStores.
+const DefaultJobRetention = time.Hour
+
+// MemoryJobStore is a JobStore that keeps jobs in memory, for
+// development or single server deployments.  Jobs and their
+// notifications are copied whenever they are stored or returned, so
+// the stored jobs are never shared with other goroutines.
+type MemoryJobStore struct {
+	// Retention is how long finished jobs are kept, so that clients
+	// have time to see their result.  Finished jobs that haven't been
+	// updated for longer than this are removed.  Zero means that
+	// finished jobs are never removed.  This is not safe to change
+	// while the store is in use.
+	Retention time.Duration
+
+	lock   sync.RWMutex
+	jobs   map[string]Job
+	nextId int
+}
+
+// NewMemoryJobStore returns an empty *MemoryJobStore, which keeps
+// finished jobs for DefaultJobRetention.
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
+		Retention: DefaultJobRetention,
+		jobs:      make(map[string]Job),
+	}
+}
+
+func (store *MemoryJobStore) Create(job *Job) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.removeExpired()
+	store.nextId++
+	job.Id = strconv.Itoa(store.nextId)
+	store.jobs[job.Id] = copyJob(job)
+	return nil
+}
+
+func (store *MemoryJobStore) Update(job *Job) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if _, ok := store.jobs[job.Id]; !ok {
+		return ErrJobNotFound
+	}
+	store.jobs[job.Id] = copyJob(job)
+	return nil
+}
+
+func (store *MemoryJobStore) Get(id string) (*Job, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	job, ok := store.jobs[id]
+	if !ok || store.expired(&job) {
+		return nil, ErrJobNotFound
+	}
+	job = copyJob(&job)
+	return &job, nil
+}
+
+// expired returns whether or not job is finished, and has been for
+// longer than the store's Retention.
+func (store *MemoryJobStore) expired(job *Job) bool {
+	return store.Retention > 0 && job.Finished() && time.Since(job.Updated) > store.Retention
+}
+
+// removeExpired removes the expired jobs from the store.  The store
+// must be locked for writing.
+func (store *MemoryJobStore) removeExpired() {
+	for id, job := range store.jobs {
+		if store.expired(&job) {
+			delete(store.jobs, id)
+		}
+	}
+}
+
+// copyJob returns a copy of job that doesn't share its notifications.
+func copyJob(job *Job) Job {
+	copied := *job
+	if job.Notifications != nil {
+		copied.Notifications = job.Notifications.clone()
+	}
+	return copied
+}
+
+// A JobFunc performs the work of a job.  It should call progress with
+// the fraction of the work that is done (from 0 to 1) as it goes.  If
+// the returned notifications contain any errors, the job has failed;
+// otherwise, it has succeeded, and the location of result (if it is a
+// Locationer) becomes the job's ResultLocation.
+type JobFunc func(progress func(float64)) (result interface{}, notifications MessageMap)
+
+// StartJob creates a job in store and performs work in a new
+// goroutine, returning the job as it was created.
+func StartJob(store JobStore, work JobFunc) (*Job, error) {
+	now := time.Now()
+	job := &Job{
+		State:         JobPending,
+		Created:       now,
+		Updated:       now,
+		Notifications: NewMessageMap(),
+	}
+	if err := store.Create(job); err != nil {
+		return nil, err
+	}
+	running := *job
+	go runJob(store, &running, work)
+	return job, nil
+}
+
+// runJob performs work, keeping the job updated in store.  Work may
+// report its progress from any goroutine, so every change to the job
+// is made while holding a lock, and store is given a copy of the job
+// as it was at that point.
+func runJob(store JobStore, job *Job, work JobFunc) {
+	var lock sync.Mutex
+	update := func(change func()) {
+		lock.Lock()
+		defer lock.Unlock()
+		change()
+		job.Updated = time.Now()
+		updated := *job
+		if err := store.Update(&updated); err != nil {
+			log.Print("ERR: could not update job ", job.Id, ": ", err)
+		}
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			update(func() {
+				job.State = JobFailed
+				job.Notifications = NewMessageMap()
+				job.Notifications.AddErrorMessage(fmt.Sprint("Job failed: ", recovered))
+			})
+		}
+	}()
+
+	update(func() {
+		job.State = JobRunning
+	})
+
+	result, notifications := work(func(progress float64) {
+		update(func() {
+			if !job.Finished() {
+				job.Progress = progress
+			}
+		})
+	})
+	if notifications == nil {
+		notifications = NewMessageMap()
+	}
+	update(func() {
+		job.Notifications = notifications
+		if notifications.NumErrors() > 0 {
+			job.State = JobFailed
+		} else {
+			job.State = JobSucceeded
+			job.Progress = 1
+			if locationer, ok := result.(Locationer); ok {
+				job.ResultLocation = URL(locationer.Location())
+			}
+		}
+	})
+}
+
+// RespondWithJob starts a job (see StartJob) and responds with 202
+// Accepted, with the Location header pointing to the job's status
+// resource.
+func RespondWithJob(ctx context.Context, store JobStore, work JobFunc, useFullDomain ...bool) error {
+	job, err := StartJob(store, work)
+	if err != nil {
+		return err
+	}
+	return RespondAccepted(ctx, nil, job, useFullDomain...)
+}
+
+// RespondWithJobStatus responds with the status resource of the job
+// with id, using the job's notifications as the notifications of the
+// response.  If there is no such job, the response is a 404 Not
+// Found.
+func RespondWithJobStatus(ctx context.Context, store JobStore, id string, useFullDomain ...bool) error {
+	job, err := store.Get(id)
+	if err == ErrJobNotFound {
+		notifications := NewMessageMap()
+		notifications.AddErrorMessage(err.Error())
+		return Respond(ctx, http.StatusNotFound, notifications, nil, useFullDomain...)
+	}
+	if err != nil {
+		return err
+	}
+
+	return Respond(ctx, http.StatusOK, job.Notifications, job, useFullDomain...)
+}
@@ -0,0 +1,135 @@
+package web_responders
+
+import (
+	"github.com/stretchr/objx"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// waitForJob polls store until the job with id is finished.
+func waitForJob(t *testing.T, store JobStore, id string) *Job {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		job, err := store.Get(id)
+		assert.NoError(t, err)
+		if job.Finished() {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("job did not finish")
+	return nil
+}
+
+func TestStartJob(t *testing.T) {
+	store := NewMemoryJobStore()
+	job, err := StartJob(store, func(progress func(float64)) (interface{}, MessageMap) {
+		progress(0.5)
+		return &testLocatedNode{Name: "imported"}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, JobPending, job.State)
+	assert.Equal(t, "/jobs/1", ResponseLocation(http.StatusAccepted, job))
+
+	job = waitForJob(t, store, job.Id)
+	assert.Equal(t, JobSucceeded, job.State)
+	assert.Equal(t, 1.0, job.Progress)
+	assert.Equal(t, URL("/nodes/imported"), job.ResultLocation)
+
+	response, err := CreateResponseWithOptions(job, WithDomain("http://example.com"))
+	assert.NoError(t, err)
+	m := response.(objx.Map)
+	assert.Equal(t, "succeeded", string(m["state"].(JobState)))
+	assert.Equal(t, "http://example.com/nodes/imported", m["result_location"])
+	assert.NotContains(t, m, "notifications")
+	assert.Equal(t, Links{"result": {{Href: "/nodes/imported"}}}, LinksFor(job))
+}
+
+func TestStartJobFailures(t *testing.T) {
+	store := NewMemoryJobStore()
+	job, _ := StartJob(store, func(progress func(float64)) (interface{}, MessageMap) {
+		notifications := NewMessageMap()
+		notifications.AddErrorMessage("Could not transcode")
+		return nil, notifications
+	})
+	job = waitForJob(t, store, job.Id)
+	assert.Equal(t, JobFailed, job.State)
+	assert.Equal(t, []string{"Could not transcode"}, job.Notifications.Errors())
+
+	job, _ = StartJob(store, func(progress func(float64)) (interface{}, MessageMap) {
+		panic("out of disk space")
+	})
+	job = waitForJob(t, store, job.Id)
+	assert.Equal(t, JobFailed, job.State)
+	assert.Equal(t, 1, job.Notifications.NumErrors())
+
+	_, err := store.Get("missing")
+	assert.Equal(t, ErrJobNotFound, err)
+}
+
+func TestMemoryJobStoreRetention(t *testing.T) {
+	store := NewMemoryJobStore()
+	assert.Equal(t, DefaultJobRetention, store.Retention)
+	store.Retention = 10 * time.Millisecond
+
+	finished, _ := StartJob(store, func(progress func(float64)) (interface{}, MessageMap) {
+		return nil, nil
+	})
+	waitForJob(t, store, finished.Id)
+	running := &Job{State: JobRunning, Updated: time.Now().Add(-time.Hour)}
+	assert.NoError(t, store.Create(running))
+
+	time.Sleep(20 * time.Millisecond)
+	_, err := store.Get(finished.Id)
+	assert.Equal(t, ErrJobNotFound, err)
+	_, err = store.Get(running.Id)
+	assert.NoError(t, err, "unfinished jobs are never removed")
+
+	// Expired jobs are removed from memory when new jobs are created.
+	assert.NoError(t, store.Create(&Job{State: JobPending, Updated: time.Now()}))
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	assert.NotContains(t, store.jobs, finished.Id)
+	assert.Equal(t, 2, len(store.jobs))
+}
+
+func TestMemoryJobStoreProgressWhilePolled(t *testing.T) {
+	store := NewMemoryJobStore()
+	job, _ := StartJob(store, func(progress func(float64)) (interface{}, MessageMap) {
+		done := make(chan bool)
+		for worker := 0; worker < 4; worker++ {
+			go func() {
+				for i := 0; i <= 100; i++ {
+					progress(float64(i) / 100)
+				}
+				done <- true
+			}()
+		}
+		for worker := 0; worker < 4; worker++ {
+			<-done
+		}
+		notifications := NewMessageMap()
+		notifications.AddInfoMessage("Imported")
+		return nil, notifications
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		polled, err := store.Get(job.Id)
+		assert.NoError(t, err)
+		assert.True(t, polled.Progress >= 0 && polled.Progress <= 1)
+		if polled.Notifications != nil {
+			polled.Notifications.Localize("fr")
+		}
+		_, err = CreateResponseWithOptions(polled)
+		assert.NoError(t, err)
+		if polled.Finished() {
+			assert.Equal(t, JobSucceeded, polled.State)
+			assert.Equal(t, 1.0, polled.Progress)
+			return
+		}
+	}
+	t.Fatal("job did not finish")
+}
@@ -0,0 +1,216 @@
+package web_responders
+
+import (
+	"fmt"
+	"github.com/stretchr/objx"
+	"sort"
+	"strings"
+)
+
+// A Link is a hypermedia link to a related resource.  Only Href is
+// required.
+type Link struct {
+	// Href is the target of the link, usually relative to the server
+	// root path (e.g. "/songs/12").  If Templated is true, Href is an
+	// RFC 6570 URI template (e.g. "/songs{?genre,page}").
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+
+	// Method is the HTTP method to use with the link, for links to
+	// actions (e.g. "POST").
+	Method string `json:"method,omitempty"`
+
+	// Title is a human readable description of the link.
+	Title string `json:"title,omitempty"`
+
+	// Type is the media type of the link's target.
+	Type string `json:"type,omitempty"`
+}
+
+// isPlain returns whether or not the link is nothing but an href.
+func (link Link) isPlain() bool {
+	return !link.Templated && link.Method == "" && link.Title == "" && link.Type == ""
+}
+
+// Absolute returns a copy of the link with domain prepended to its
+// href, if the href is relative to the server root path.
+func (link Link) Absolute(domain string) Link {
+	if isRootRelative(link.Href) {
+		link.Href = domain + link.Href
+	}
+	return link
+}
+
+// isRootRelative returns whether or not href is relative to the server
+// root path, i.e. it starts with a single "/".  Hrefs starting with
+// "//" are relative to the scheme (e.g. "//cdn.radiobox.com/art.png"),
+// and already name their own host.
+func isRootRelative(href string) bool {
+	return strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//")
+}
+
+// ResponseValue returns the link as a map, leaving out empty values.
+func (link Link) ResponseValue() map[string]interface{} {
+	value := map[string]interface{}{"href": link.Href}
+	if link.Templated {
+		value["templated"] = true
+	}
+	if link.Method != "" {
+		value["method"] = link.Method
+	}
+	if link.Title != "" {
+		value["title"] = link.Title
+	}
+	if link.Type != "" {
+		value["type"] = link.Type
+	}
+	return value
+}
+
+// ResponseValueWithOptions returns the link as a map, with the
+// domain of options prepended to its href.
+func (link Link) ResponseValueWithOptions(joins objx.Map, options *ResponseOptions) interface{} {
+	return link.Absolute(options.Domain).ResponseValue()
+}
+
+// Links maps link relations (rels) to the links for that relation.
+type Links map[string][]Link
+
+// LinksFor returns the links for value, if it is a HypermediaLinker
+// or a RelatedLinker.  The links are not modified, so any relative
+// links are still relative.
+func LinksFor(value interface{}) Links {
+	links := Links{}
+	if linker, ok := value.(HypermediaLinker); ok {
+		for rel, relLinks := range linker.HypermediaLinks() {
+			links.Add(rel, relLinks...)
+		}
+	} else if linker, ok := value.(RelatedLinker); ok {
+		for rel, href := range linker.RelatedLinks() {
+			links.Add(rel, Link{Href: href})
+		}
+	}
+	return links
+}
+
+// Add adds links to rel.
+func (links Links) Add(rel string, relLinks ...Link) {
+	links[rel] = append(links[rel], relLinks...)
+}
+
+// Rels returns the rels that have links, sorted.
+func (links Links) Rels() []string {
+	rels := make([]string, 0, len(links))
+	for rel, relLinks := range links {
+		if len(relLinks) > 0 {
+			rels = append(rels, rel)
+		}
+	}
+	sort.Strings(rels)
+	return rels
+}
+
+// Absolute returns a copy of links with domain prepended to each
+// relative href.
+func (links Links) Absolute(domain string) Links {
+	absolute := make(Links, len(links))
+	for rel, relLinks := range links {
+		absoluteLinks := make([]Link, 0, len(relLinks))
+		for _, link := range relLinks {
+			absoluteLinks = append(absoluteLinks, link.Absolute(domain))
+		}
+		absolute[rel] = absoluteLinks
+	}
+	return absolute
+}
+
+// ResponseValue returns links in the format used in our envelope's
+// meta.  To stay compatible with RelatedLinker, a rel with a single
+// plain link (i.e. nothing but an href) is just the href string.  A
+// rel with a single link that has other details is an object (e.g.
+// {"href": "/songs/12", "method": "DELETE"}), and a rel with more
+// than one link is a list of objects.
+func (links Links) ResponseValue() map[string]interface{} {
+	value := make(map[string]interface{}, len(links))
+	for _, rel := range links.Rels() {
+		relLinks := links[rel]
+		switch {
+		case len(relLinks) == 1 && relLinks[0].isPlain():
+			value[rel] = relLinks[0].Href
+		case len(relLinks) == 1:
+			value[rel] = relLinks[0].ResponseValue()
+		default:
+			list := make([]interface{}, 0, len(relLinks))
+			for _, link := range relLinks {
+				list = append(list, link.ResponseValue())
+			}
+			value[rel] = list
+		}
+	}
+	return value
+}
+
+// HeaderValue returns links in the format of an RFC 8288 Link
+// header.  Templated links are left out, since a Link header can
+// only contain URIs.  The method of a link is included as a "method"
+// parameter.  Characters that would end the href early (e.g. ">" or
+// ",") are percent-encoded, and parameters are written as RFC 7230
+// quoted-strings.
+func (links Links) HeaderValue() string {
+	values := []string{}
+	for _, rel := range links.Rels() {
+		for _, link := range links[rel] {
+			if link.Templated {
+				continue
+			}
+			value := "<" + headerHref(link.Href) + ">; rel=" + quoteHeaderParam(rel)
+			if link.Title != "" {
+				value += "; title=" + quoteHeaderParam(link.Title)
+			}
+			if link.Type != "" {
+				value += "; type=" + quoteHeaderParam(link.Type)
+			}
+			if link.Method != "" {
+				value += "; method=" + quoteHeaderParam(link.Method)
+			}
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// headerHref percent-encodes the characters in href that can't appear
+// between the angle brackets of a Link header value: whitespace and
+// other control characters, "<", ">" and ",".
+func headerHref(href string) string {
+	encoded := make([]byte, 0, len(href))
+	for i := 0; i < len(href); i++ {
+		c := href[i]
+		switch {
+		case c <= ' ', c == 0x7f, c == '<', c == '>', c == ',':
+			encoded = append(encoded, fmt.Sprintf("%%%02X", c)...)
+		default:
+			encoded = append(encoded, c)
+		}
+	}
+	return string(encoded)
+}
+
+// quoteHeaderParam returns value as a quoted-string (RFC 7230, section
+// 3.2.6).  Quotes and backslashes are escaped with a backslash, and
+// control characters (which can't be quoted) are left out.
+func quoteHeaderParam(value string) string {
+	quoted := make([]byte, 0, len(value)+2)
+	quoted = append(quoted, '"')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"', c == '\\':
+			quoted = append(quoted, '\\', c)
+		case c < ' ' && c != '\t', c == 0x7f:
+		default:
+			quoted = append(quoted, c)
+		}
+	}
+	return string(append(quoted, '"'))
+}
@@ -0,0 +1,67 @@
+package web_responders
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type oldLinkedSong struct{}
+
+func (song oldLinkedSong) RelatedLinks() map[string]string {
+	return map[string]string{"artist": "/artists/3"}
+}
+
+type linkedSong struct{}
+
+func (song linkedSong) HypermediaLinks() Links {
+	return Links{
+		"artist": {{Href: "/artists/3"}},
+		"delete": {{Href: "/songs/12", Method: "DELETE", Title: "Remove song"}},
+		"search": {{Href: "/songs{?title}", Templated: true}},
+		"stream": {
+			{Href: "/songs/12.mp3", Type: "audio/mpeg"},
+			{Href: "/songs/12.ogg", Type: "audio/ogg"},
+		},
+	}
+}
+
+func TestLinksForRelatedLinker(t *testing.T) {
+	links := LinksFor(oldLinkedSong{}).Absolute("http://example.com")
+	assert.Equal(t, Links{"artist": {{Href: "http://example.com/artists/3"}}}, links)
+	assert.Equal(t, map[string]interface{}{"artist": "http://example.com/artists/3"}, links.ResponseValue())
+	assert.Empty(t, LinksFor(struct{}{}))
+}
+
+func TestLinksResponseValue(t *testing.T) {
+	value := LinksFor(linkedSong{}).ResponseValue()
+	assert.Equal(t, "/artists/3", value["artist"])
+	assert.Equal(t, map[string]interface{}{
+		"href":   "/songs/12",
+		"method": "DELETE",
+		"title":  "Remove song",
+	}, value["delete"])
+	assert.Equal(t, map[string]interface{}{"href": "/songs{?title}", "templated": true}, value["search"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"href": "/songs/12.mp3", "type": "audio/mpeg"},
+		map[string]interface{}{"href": "/songs/12.ogg", "type": "audio/ogg"},
+	}, value["stream"])
+}
+
+func TestLinksHeaderValue(t *testing.T) {
+	header := LinksFor(linkedSong{}).Absolute("http://example.com").HeaderValue()
+	assert.Equal(t, `<http://example.com/artists/3>; rel="artist", `+
+		`<http://example.com/songs/12>; rel="delete"; title="Remove song"; method="DELETE", `+
+		`<http://example.com/songs/12.mp3>; rel="stream"; type="audio/mpeg", `+
+		`<http://example.com/songs/12.ogg>; rel="stream"; type="audio/ogg"`, header)
+}
+
+func TestLinkAbsoluteSchemeRelative(t *testing.T) {
+	assert.Equal(t, "//cdn.example.com/art.png", Link{Href: "//cdn.example.com/art.png"}.Absolute("http://example.com").Href)
+	assert.Equal(t, "//cdn.example.com/art.png", URL("//cdn.example.com/art.png").Absolute("http://example.com"))
+	assert.Equal(t, "http://example.com/art.png", URL("/art.png").Absolute("http://example.com"))
+}
+
+func TestLinksHeaderValueEscaping(t *testing.T) {
+	links := Links{"next": {{Href: "/songs?a=1,2>\r\nX-Evil: 1", Title: "Say \"hi\" \\ bye\r\n"}}}
+	assert.Equal(t, `</songs?a=1%2C2%3E%0D%0AX-Evil:%201>; rel="next"; title="Say \"hi\" \\ bye"`, links.HeaderValue())
+}
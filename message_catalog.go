@@ -0,0 +1,205 @@
+package web_responders
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A MessageCode identifies a message in the message catalog.  Codes
+// are usually dot separated, e.g. "input.missing".
+type MessageCode string
+
+// Codes for the messages used within this package.
+const (
+	MessageWrongType     MessageCode = "input.wrong_type"
+	MessageMissingInput  MessageCode = "input.missing"
+	MessageNoTargetField MessageCode = "input.no_target_field"
+)
+
+// DefaultLocale is the locale used for messages that have no
+// translation in the requested locale.
+var DefaultLocale = "en"
+
+// messageCatalog holds the translations for each locale.
+var messageCatalog = struct {
+	sync.RWMutex
+	locales map[string]map[MessageCode]string
+}{locales: make(map[string]map[MessageCode]string)}
+
+func init() {
+	AddMessages("en", map[MessageCode]string{
+		MessageWrongType:     "Input is of the wrong type and cannot be converted",
+		MessageMissingInput:  "No input for required field",
+		MessageNoTargetField: "No target field found for this input",
+	})
+}
+
+// AddMessages adds translations for locale to the message catalog.
+// The translations may contain fmt verbs, which will be filled in
+// with the arguments of the LocalizedMessage.
+func AddMessages(locale string, messages map[MessageCode]string) {
+	locale = normalizeLocale(locale)
+	messageCatalog.Lock()
+	defer messageCatalog.Unlock()
+	translations, ok := messageCatalog.locales[locale]
+	if !ok {
+		translations = make(map[MessageCode]string, len(messages))
+		messageCatalog.locales[locale] = translations
+	}
+	for code, message := range messages {
+		translations[code] = message
+	}
+}
+
+// TranslateMessage returns the message for code in locale.  If there
+// is no translation for locale (e.g. "pt-br"), the translation for
+// its language ("pt") is used, and then the translation for
+// DefaultLocale.  If there is no translation at all, the code itself
+// is returned.
+func TranslateMessage(locale string, code MessageCode, args ...interface{}) string {
+	messageCatalog.RLock()
+	defer messageCatalog.RUnlock()
+	for _, candidate := range localeFallbacks(locale) {
+		if message, ok := messageCatalog.locales[candidate][code]; ok {
+			if len(args) > 0 {
+				return fmt.Sprintf(message, args...)
+			}
+			return message
+		}
+	}
+	return string(code)
+}
+
+// localeFallbacks returns the locales to check, in order, when
+// looking up a message for locale.
+func localeFallbacks(locale string) []string {
+	locale = normalizeLocale(locale)
+	fallbacks := make([]string, 0, 3)
+	if locale != "" {
+		fallbacks = append(fallbacks, locale)
+		if index := strings.IndexRune(locale, '-'); index != -1 {
+			fallbacks = append(fallbacks, locale[:index])
+		}
+	}
+	return append(fallbacks, normalizeLocale(DefaultLocale))
+}
+
+// normalizeLocale converts a locale to the format used as a key in the
+// catalog, e.g. "pt_BR" becomes "pt-br".
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// localeLanguage returns the language of a normalized locale, e.g.
+// "pt" for "pt-br".
+func localeLanguage(locale string) string {
+	if index := strings.IndexRune(locale, '-'); index != -1 {
+		return locale[:index]
+	}
+	return locale
+}
+
+// hasLocale returns whether or not there are any messages for locale
+// (or its language) in the catalog.  Locales with the same language
+// as the DefaultLocale (e.g. "en-US" when the default is "en") always
+// have messages.
+func hasLocale(locale string) bool {
+	locale = normalizeLocale(locale)
+	language := localeLanguage(locale)
+	if language == localeLanguage(normalizeLocale(DefaultLocale)) {
+		return true
+	}
+	messageCatalog.RLock()
+	defer messageCatalog.RUnlock()
+	if _, ok := messageCatalog.locales[locale]; ok {
+		return true
+	}
+	_, ok := messageCatalog.locales[language]
+	return ok
+}
+
+// NegotiateLocale picks the locale with the highest weight from an
+// Accept-Language header that has messages in the catalog (including
+// locales with the same language as the DefaultLocale), falling back
+// to DefaultLocale.
+func NegotiateLocale(acceptLanguage string) string {
+	type weightedLocale struct {
+		locale string
+		weight float64
+	}
+	candidates := []weightedLocale{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(part, ";")
+		locale := strings.TrimSpace(params[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = q
+				}
+			}
+		}
+		if weight > 0 {
+			candidates = append(candidates, weightedLocale{locale, weight})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+	for _, candidate := range candidates {
+		if hasLocale(candidate.locale) {
+			return candidate.locale
+		}
+	}
+	return DefaultLocale
+}
+
+// A Translatable is a value that can be translated to a locale.
+// MessageMap keeps track of Translatable messages, so that they can
+// be translated once the locale of the response is known.
+type Translatable interface {
+	Translate(locale string) string
+}
+
+// A LocalizedMessage is a message from the catalog, along with the
+// arguments used to fill in its fmt verbs.  It implements error, so
+// InputValidator implementations can return one to have their errors
+// translated:
+//
+//	func (value *Rating) ValidateInput(input interface{}) error {
+//	    if !isRating(input) {
+//	        return web_responders.Localize("rating.out_of_range", 1, 5)
+//	    }
+//	    return nil
+//	}
+type LocalizedMessage struct {
+	Code MessageCode
+	Args []interface{}
+}
+
+// Localize returns a *LocalizedMessage for code and args.
+func Localize(code MessageCode, args ...interface{}) *LocalizedMessage {
+	return &LocalizedMessage{Code: code, Args: args}
+}
+
+// Translate returns the message in locale.
+func (message *LocalizedMessage) Translate(locale string) string {
+	return TranslateMessage(locale, message.Code, message.Args...)
+}
+
+// String returns the message in DefaultLocale.
+func (message *LocalizedMessage) String() string {
+	return message.Translate(DefaultLocale)
+}
+
+// Error returns the message in DefaultLocale.
+func (message *LocalizedMessage) Error() string {
+	return message.String()
+}
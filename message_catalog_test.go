@@ -0,0 +1,74 @@
+package web_responders
+
+import (
+	"encoding/json"
+	"github.com/stretchr/goweb"
+	"github.com/stretchr/goweb/webcontext"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	AddMessages("fr", map[MessageCode]string{MessageMissingInput: "Aucune valeur pour ce champ obligatoire"})
+
+	assert.Equal(t, "fr-CA", NegotiateLocale("de;q=0.9, fr-CA, en;q=0.5"))
+	assert.Equal(t, "en", NegotiateLocale("de, en;q=0.5"))
+	assert.Equal(t, "en-US", NegotiateLocale("en-US, fr;q=0.5"))
+	assert.Equal(t, "en-GB", NegotiateLocale("fr;q=0.5, en-GB;q=0.8"))
+	assert.Equal(t, DefaultLocale, NegotiateLocale(""))
+}
+
+func TestMessageMapLocalize(t *testing.T) {
+	AddMessages("fr", map[MessageCode]string{MessageMissingInput: "Aucune valeur pour ce champ obligatoire"})
+
+	notifications := NewMessageMap()
+	notifications.SetInputMessage("title", Localize(MessageMissingInput))
+	notifications.SetInputMessage("rating", Localize(MessageWrongType))
+	notifications.AddErrorMessage("Could not save:", Localize(MessageMissingInput))
+	assert.Equal(t, "No input for required field", notifications.InputMessages()["title"])
+
+	notifications.Localize("fr-CA")
+	assert.Equal(t, "Aucune valeur pour ce champ obligatoire", notifications.InputMessages()["title"])
+	assert.Equal(t, "Input is of the wrong type and cannot be converted", notifications.InputMessages()["rating"])
+	assert.Equal(t, []string{"Could not save: Aucune valeur pour ce champ obligatoire"}, notifications.Errors())
+
+	encoded, err := json.Marshal(notifications)
+	assert.NoError(t, err)
+	decoded := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, 4, len(decoded))
+
+	// A notification key can't collide with the translation sources.
+	notifications["sources"] = []string{"catalog"}
+	notifications.Localize("en")
+	assert.Equal(t, "No input for required field", notifications.InputMessages()["title"])
+	assert.Equal(t, []string{"catalog"}, notifications["sources"])
+
+	clone := notifications.clone()
+	clone.Localize("fr")
+	assert.Equal(t, "Aucune valeur pour ce champ obligatoire", clone.InputMessages()["title"])
+	assert.Equal(t, "No input for required field", notifications.InputMessages()["title"])
+}
+
+func TestRespondLocalizesCopy(t *testing.T) {
+	AddMessages("fr", map[MessageCode]string{MessageMissingInput: "Aucune valeur pour ce champ obligatoire"})
+
+	request, _ := http.NewRequest("GET", "http://example.com/songs/1", nil)
+	request.Header.Set("Accept-Language", "fr")
+	recorder := httptest.NewRecorder()
+	ctx := webcontext.NewWebContext(recorder, request, goweb.CodecService)
+
+	notifications := NewMessageMap()
+	notifications.SetInputMessage("title", Localize(MessageMissingInput))
+	assert.NoError(t, Respond(ctx, http.StatusBadRequest, notifications, nil))
+
+	localized := ctx.CodecOptions()["notifications"].(MessageMap)
+	assert.Equal(t, "Aucune valeur pour ce champ obligatoire", localized.InputMessages()["title"])
+	assert.Equal(t, "No input for required field", notifications.InputMessages()["title"])
+
+	encoded, err := json.Marshal(localized)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encoded), "sources")
+}
@@ -1,7 +1,9 @@
 package web_responders
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/stretchr/objx"
 	"log"
 	"strings"
 )
@@ -31,12 +33,20 @@ func (mm MessageMap) log(severity string, messages ...interface{}) {
 }
 
 func (mm MessageMap) joinMessages(messages ...interface{}) string {
+	return mm.translateMessages(DefaultLocale, messages...)
+}
+
+// translateMessages joins messages the same way as joinMessages,
+// translating any Translatable messages to locale.
+func (mm MessageMap) translateMessages(locale string, messages ...interface{}) string {
 	response := ""
 	for _, message := range messages {
 		if response != "" {
 			response += " "
 		}
 		switch src := message.(type) {
+		case Translatable:
+			response += src.Translate(locale)
 		case fmt.Stringer:
 			response += src.String()
 		case error:
@@ -50,7 +60,121 @@ func (mm MessageMap) joinMessages(messages ...interface{}) string {
 
 func (mm MessageMap) addMessage(severity string, messages ...interface{}) {
 	go mm.log(severity, messages...)
-	mm[severity] = append(mm[severity].([]string), mm.joinMessages(messages...))
+	list := mm[severity].([]string)
+	if isTranslatable(messages) {
+		sources := mm.sources(true)
+		if sources.messages[severity] == nil {
+			sources.messages[severity] = make(map[int][]interface{})
+		}
+		sources.messages[severity][len(list)] = messages
+	}
+	mm[severity] = append(list, mm.joinMessages(messages...))
+}
+
+// messageSources holds the original values of any messages that
+// contain a Translatable value, so that they can be translated when
+// the locale of the response is known.
+type messageSources struct {
+	// messages holds the sources by severity, then by index.
+	messages map[string]map[int][]interface{}
+
+	// inputs holds the sources by input name.
+	inputs map[string][]interface{}
+}
+
+func newMessageSources() *messageSources {
+	return &messageSources{
+		messages: make(map[string]map[int][]interface{}),
+		inputs:   make(map[string][]interface{}),
+	}
+}
+
+// messageSourcesKey is the key used to store the messageSources of a
+// MessageMap.  It starts with a NUL byte, so that it can't collide
+// with a notification key, and it is left out whenever the MessageMap
+// is encoded or used in a response (see visible).
+const messageSourcesKey = "\x00sources"
+
+// sources returns the messageSources of the MessageMap, adding them
+// to the map if create is true.  It returns nil if there are no
+// sources and create is false.
+func (mm MessageMap) sources(create bool) *messageSources {
+	sources, ok := mm[messageSourcesKey].(*messageSources)
+	if !ok && create {
+		sources = newMessageSources()
+		mm[messageSourcesKey] = sources
+	}
+	return sources
+}
+
+func isTranslatable(messages []interface{}) bool {
+	for _, message := range messages {
+		if _, ok := message.(Translatable); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Localize translates all of the messages in the map that were added
+// with a Translatable value (e.g. a *LocalizedMessage) to locale.
+// Respond translates a copy of its notifications this way, using the
+// locale from the Accept-Language header.
+func (mm MessageMap) Localize(locale string) {
+	sources := mm.sources(false)
+	if sources == nil {
+		return
+	}
+	for severity, messages := range sources.messages {
+		list := mm[severity].([]string)
+		for index, source := range messages {
+			list[index] = mm.translateMessages(locale, source...)
+		}
+	}
+	inputs := mm.InputMessages()
+	for input, source := range sources.inputs {
+		inputs[input] = mm.translateMessages(locale, source...)
+	}
+}
+
+// localized returns a copy of the MessageMap translated to locale,
+// leaving the original untouched.  Respond uses this so that the same
+// MessageMap can be sent to clients with different locales.
+func (mm MessageMap) localized(locale string) MessageMap {
+	if mm == nil {
+		return nil
+	}
+	localized := mm.clone()
+	localized.Localize(locale)
+	return localized
+}
+
+// visible returns a copy of the MessageMap without any of the values
+// used internally for translation.
+func (mm MessageMap) visible() map[string]interface{} {
+	visible := make(map[string]interface{}, len(mm))
+	for key, value := range mm {
+		if key != messageSourcesKey {
+			visible[key] = value
+		}
+	}
+	return visible
+}
+
+// MarshalJSON encodes the MessageMap without any of the values used
+// internally for translation.
+func (mm MessageMap) MarshalJSON() ([]byte, error) {
+	if mm == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(mm.visible())
+}
+
+// ProfileResponseValue leaves out the values used internally for
+// translation when a MessageMap is part of a response value (e.g. in
+// a BatchResult).  MessageMaps look the same in every profile.
+func (mm MessageMap) ProfileResponseValue(profile Profile, joins objx.Map) interface{} {
+	return mm.visible()
 }
 
 // AddErrorMessage adds an error message to the message map.
@@ -105,8 +229,46 @@ func (mm MessageMap) NumInfos() int {
 func (mm MessageMap) SetInputMessage(input string, messages ...interface{}) {
 	inputErrs := mm.InputMessages()
 	inputErrs[input] = mm.joinMessages(messages...)
+	if isTranslatable(messages) {
+		mm.sources(true).inputs[input] = messages
+	} else if sources := mm.sources(false); sources != nil {
+		delete(sources.inputs, input)
+	}
 }
 
 func (mm MessageMap) InputMessages() map[string]string {
 	return mm["input"].(map[string]string)
 }
+
+// clone returns a copy of the MessageMap that can be changed (e.g.
+// localized) without changing the original.
+func (mm MessageMap) clone() MessageMap {
+	clone := make(MessageMap, len(mm))
+	for key, value := range mm {
+		switch src := value.(type) {
+		case []string:
+			clone[key] = append([]string(nil), src...)
+		case map[string]string:
+			inputs := make(map[string]string, len(src))
+			for input, message := range src {
+				inputs[input] = message
+			}
+			clone[key] = inputs
+		case *messageSources:
+			sources := newMessageSources()
+			for severity, messages := range src.messages {
+				sources.messages[severity] = make(map[int][]interface{}, len(messages))
+				for index, source := range messages {
+					sources.messages[severity][index] = source
+				}
+			}
+			for input, source := range src.inputs {
+				sources.inputs[input] = source
+			}
+			clone[key] = sources
+		default:
+			clone[key] = value
+		}
+	}
+	return clone
+}
@@ -0,0 +1,10 @@
+package web_responders
+
+// A MetaHeaderCodec is a codec for a format that has no room for our
+// response envelope (for example, CSV).  When the codec chosen for a
+// response is a MetaHeaderCodec and WritesMetaHeaders returns true,
+// Respond will send the meta values (e.g. the status code and
+// notifications) as response headers instead.
+type MetaHeaderCodec interface {
+	WritesMetaHeaders() bool
+}
@@ -0,0 +1,150 @@
+package web_responders
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"time"
+)
+
+var (
+	nullableType = reflect.TypeOf((*Nullable)(nil)).Elem()
+	valuerType   = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// A Nullable is a value that may be null, e.g. an optional value
+// type.  Null values are nil in responses; anything else is
+// represented by the response value of NullableValue.  Nil is always
+// accepted as input for fields of a Nullable type; other input is
+// checked using Scan, if the type also implements
+// "database/sql".Scanner.
+//
+// The "database/sql".Null* types, and driver.Valuer structs with the
+// same structure, don't need to implement Nullable; see
+// nullableValue.
+type Nullable interface {
+	IsNull() bool
+	NullableValue() interface{}
+}
+
+// scanner matches "database/sql".Scanner, which most nullable
+// database types implement.
+type scanner interface {
+	Scan(src interface{}) error
+}
+
+// isNullableType returns whether or not values of t may be null.
+// This matches the values that nullableValue and createStructResponse
+// render as null: Nullable values, "database/sql".Null* types (see
+// nullableFields), and driver.Valuer structs with the same structure
+// (see hasNullShape).  Any other driver.Valuer is rendered normally,
+// so it isn't nullable as input either.
+func isNullableType(t reflect.Type) bool {
+	if t.Implements(nullableType) || reflect.PtrTo(t).Implements(nullableType) {
+		return true
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	if _, _, ok := nullableFields(t); ok {
+		return true
+	}
+	return (t.Implements(valuerType) || reflect.PtrTo(t).Implements(valuerType)) && hasNullShape(t)
+}
+
+// nullableValue returns the value that should be used in place of data
+// in a response, if data is Nullable or a driver.Valuer.  A nil value
+// means that data is null.
+//
+// Driver values are only used in place of structs with the structure
+// of a sql.Null* type (a Valid field and a single value field, e.g.
+// third party types that embed a sql.Null* type; see hasNullShape),
+// and only when the driver value is a basic value or a time.Time.
+// Other driver.Valuer structs are rendered normally, since their
+// fields mean more to clients than whatever they store in the
+// database, and driver.Valuer values that aren't structs are only
+// checked for null.  The sql.Null* types themselves are left to
+// createStructResponse, which keeps the original type of their value.
+// ok is false when data should be rendered normally.
+func nullableValue(data interface{}) (value interface{}, ok bool) {
+	if dataValue := reflect.ValueOf(data); dataValue.Kind() == reflect.Ptr && dataValue.IsNil() {
+		return nil, false
+	}
+	if src, ok := asNullable(data); ok {
+		if src.IsNull() {
+			return nil, true
+		}
+		return src.NullableValue(), true
+	}
+	switch src := data.(type) {
+	case driver.Valuer:
+		structValue := reflect.Indirect(reflect.ValueOf(data))
+		if structValue.Kind() != reflect.Struct {
+			driverValue, err := src.Value()
+			return nil, err == nil && driverValue == nil
+		}
+		if _, _, ok := nullableFields(structValue.Type()); ok {
+			// The value field keeps its original type, so let
+			// createStructResponse handle it.
+			return nil, false
+		}
+		if !hasNullShape(structValue.Type()) {
+			return nil, false
+		}
+		driverValue, err := src.Value()
+		if err != nil {
+			return nil, false
+		}
+		if driverValue == nil {
+			return nil, true
+		}
+		switch driverValue.(type) {
+		case int64, float64, bool, string, time.Time:
+			return driverValue, true
+		}
+	}
+	return nil, false
+}
+
+// asNullable returns data as a Nullable, if either data or a pointer
+// to data implements Nullable.  Like asIsZeroer, values are copied so
+// that methods with pointer receivers are used no matter how the value
+// was passed.
+func asNullable(data interface{}) (Nullable, bool) {
+	if nullable, ok := data.(Nullable); ok {
+		return nullable, true
+	}
+	value := reflect.ValueOf(data)
+	if !value.IsValid() || !reflect.PtrTo(value.Type()).Implements(nullableType) {
+		return nil, false
+	}
+	addressable := reflect.New(value.Type())
+	addressable.Elem().Set(value)
+	return addressable.Interface().(Nullable), true
+}
+
+// hasNullShape returns whether or not structType has the structure of
+// a "database/sql".Null* type: an exported bool field named Valid and
+// one other exported field holding the value.  Fields of embedded
+// structs (e.g. an embedded sql.NullString) are counted as well.
+func hasNullShape(structType reflect.Type) bool {
+	_, ok := nullShapeValue(structType)
+	return ok
+}
+
+// nullShapeValue returns the value field of a struct with the
+// structure checked by hasNullShape.
+func nullShapeValue(structType reflect.Type) (valueField reflect.StructField, ok bool) {
+	valid, values := false, 0
+	for _, field := range reflect.VisibleFields(structType) {
+		if field.Anonymous || field.PkgPath != "" {
+			continue
+		}
+		if field.Name == "Valid" && field.Type.Kind() == reflect.Bool {
+			valid = true
+			continue
+		}
+		valueField = field
+		values++
+	}
+	return valueField, valid && values == 1
+}
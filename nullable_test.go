@@ -0,0 +1,149 @@
+package web_responders
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/stretchr/objx"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+type testOptional struct {
+	value interface{}
+}
+
+func (optional testOptional) IsNull() bool {
+	return optional.value == nil
+}
+
+func (optional testOptional) NullableValue() interface{} {
+	return optional.value
+}
+
+// testPointerOptional implements Nullable with pointer receivers.
+type testPointerOptional struct {
+	value interface{}
+}
+
+func (optional *testPointerOptional) IsNull() bool {
+	return optional.value == nil
+}
+
+func (optional *testPointerOptional) NullableValue() interface{} {
+	return optional.value
+}
+
+// testThirdPartyNull mimics third party null types, which embed a
+// sql.Null* type and have a different name.
+type testThirdPartyNull struct {
+	sql.NullString
+}
+
+// testMoney is a driver.Valuer with fields of its own, which should
+// be rendered as an object rather than as its database value.
+type testMoney struct {
+	Amount   int64
+	Currency string
+}
+
+func (money testMoney) Value() (driver.Value, error) {
+	return fmt.Sprintf("%d %s", money.Amount, money.Currency), nil
+}
+
+// testNullShapedValuer is a driver.Valuer with the structure of a
+// sql.Null* type, which doesn't implement Scan.
+type testNullShapedValuer struct {
+	Code  string
+	Valid bool
+}
+
+func (code testNullShapedValuer) Value() (driver.Value, error) {
+	if !code.Valid {
+		return nil, nil
+	}
+	return code.Code, nil
+}
+
+func TestCreateResponseNullables(t *testing.T) {
+	type nullables struct {
+		Generic       sql.Null[int]
+		NullGeneric   sql.Null[int]
+		Optional      testOptional
+		NullOptional  testOptional
+		ThirdParty    testThirdPartyNull
+		NullPointer   *sql.NullInt64
+		Pointer       *sql.NullInt64
+		Omitted       testOptional `response:"omitted,omitempty"`
+		Money         testMoney
+		Pointered     testPointerOptional
+		NullPointered testPointerOptional
+	}
+	value := nullables{
+		Generic:    sql.Null[int]{V: 3, Valid: true},
+		Optional:   testOptional{"song"},
+		ThirdParty: testThirdPartyNull{sql.NullString{String: "isrc", Valid: true}},
+		Pointer:    &sql.NullInt64{Int64: 7, Valid: true},
+		Money:      testMoney{Amount: 250, Currency: "USD"},
+		Pointered:  testPointerOptional{"album"},
+	}
+
+	response, err := CreateResponseWithOptions(value)
+	assert.NoError(t, err)
+	assert.Equal(t, objx.Map{
+		"generic":       3,
+		"nullgeneric":   nil,
+		"optional":      "song",
+		"nulloptional":  nil,
+		"thirdparty":    "isrc",
+		"nullpointer":   nil,
+		"pointer":       int64(7),
+		"money":         objx.Map{"amount": int64(250), "currency": "USD"},
+		"pointered":     "album",
+		"nullpointered": nil,
+	}, response)
+
+	response, err = CreateResponseWithOptions(testPointerOptional{"album"})
+	assert.NoError(t, err)
+	assert.Equal(t, "album", response)
+
+	response, err = CreateResponseWithOptions(testMoney{Amount: 250, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, objx.Map{"amount": int64(250), "currency": "USD"}, response)
+}
+
+func TestCheckForInputErrorNullables(t *testing.T) {
+	cases := []struct {
+		fieldType interface{}
+		value     interface{}
+		valid     bool
+	}{
+		{sql.NullString{}, nil, true},
+		{sql.NullString{}, "isrc", true},
+		{sql.NullString{}, 3.0, false},
+		{sql.Null[int]{}, nil, true},
+		{sql.Null[int]{}, 3.0, true},
+		{sql.Null[int]{}, "three", false},
+		{testThirdPartyNull{}, nil, true},
+		{testOptional{}, nil, true},
+		{testPointerOptional{}, nil, true},
+		{testMoney{}, nil, false},
+		{testMoney{}, "garbage", false},
+		{testMoney{}, map[string]interface{}{"amount": 250.0}, false},
+		{testNullShapedValuer{}, nil, true},
+		{testNullShapedValuer{}, "isrc", true},
+		{testNullShapedValuer{}, 3.0, false},
+		{new(int), nil, true},
+		{new(int), 3.0, true},
+		{0, nil, false},
+	}
+	for _, c := range cases {
+		err := checkForInputError(reflect.TypeOf(c.fieldType), c.value)
+		if c.valid {
+			assert.NoError(t, err, "%T should accept %v", c.fieldType, c.value)
+		} else {
+			assert.Error(t, err, "%T should not accept %v", c.fieldType, c.value)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package web_responders
+
+import (
+	"github.com/stretchr/objx"
+	"reflect"
+)
+
+// A Profile is a named level of detail for response values.  The
+// profile of a value is chosen by the "profile" joins option for its
+// path (e.g. joins={"artist":{"profile":"full"}}), falling back to
+// ResponseOptions.Profile.  For backward compatibility, the joins
+// option "type":"full" chooses ProfileFull.
+type Profile string
+
+const (
+	// ProfileSummary is for values listed in collections or nested
+	// in other values, where only the most important details are
+	// needed.
+	ProfileSummary Profile = "summary"
+
+	// ProfileDefault is the profile used when none is requested.
+	// Sub-values that implement ResponseValueCreator (or one of the
+	// similar interfaces) use their own response value.
+	ProfileDefault Profile = "default"
+
+	// ProfileFull renders every field of a value.  Sub-values skip
+	// ResponseValueCreator and similar interfaces, the same as the
+	// old "type":"full" joins option.
+	ProfileFull Profile = "full"
+
+	// ProfileAdmin is for responses to administrators, which may
+	// include private details.  Clients can't choose it; see
+	// ClientProfiles.
+	ProfileAdmin Profile = "admin"
+)
+
+// ClientProfiles are the profiles that clients may choose, using the
+// "profile" joins option or the profile query parameter read by
+// Respond.  Other profiles can only be chosen by the server, using
+// ResponseOptions.Profile or the "profile" codec option.
+var ClientProfiles = map[Profile]bool{
+	ProfileSummary: true,
+	ProfileDefault: true,
+	ProfileFull:    true,
+}
+
+// A ProfileResponseValueCreator is a type that has a different
+// response value for each Profile.  It is used in place of
+// ResponseValueCreator (and similar interfaces) whenever a type
+// implements both.
+//
+//	func (song *Song) ProfileResponseValue(profile web_responders.Profile, joins objx.Map) interface{} {
+//	    switch profile {
+//	    case web_responders.ProfileSummary:
+//	        return map[string]interface{}{"id": song.Id, "title": song.Title}
+//	    case web_responders.ProfileAdmin:
+//	        return adminSong{song}
+//	    }
+//	    return *song
+//	}
+type ProfileResponseValueCreator interface {
+
+	// ProfileResponseValue should return the value that will be used
+	// to represent the underlying value in a response with profile.
+	// The objx.Map is the same joins options that would be passed to
+	// ResponseValue.
+	ProfileResponseValue(profile Profile, joins objx.Map) interface{}
+}
+
+// profile returns the Profile for a value with the joins options.
+// Joins usually come from the client, so only ClientProfiles may be
+// chosen with them; anything else results in an *OptionError.
+func (state *responseState) profile(joins objx.Map) (Profile, error) {
+	if name := joins.Get("profile").Str(); name != "" {
+		profile := Profile(name)
+		if !ClientProfiles[profile] {
+			return "", &OptionError{Path: state.pathString(), Message: "Unknown profile: " + name}
+		}
+		return profile, nil
+	}
+	if joins.Get("type").Str() == "full" {
+		return ProfileFull, nil
+	}
+	if state.options.Profile == "" {
+		return ProfileDefault, nil
+	}
+	return state.options.Profile, nil
+}
+
+// isNilPointer returns whether or not data is a nil pointer, which
+// can't be asked for its response value.
+func isNilPointer(data interface{}) bool {
+	value := reflect.ValueOf(data)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
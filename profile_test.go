@@ -0,0 +1,104 @@
+package web_responders
+
+import (
+	"github.com/stretchr/objx"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testProfiledArtist struct {
+	Id     int
+	Name   string
+	Secret string
+}
+
+func (artist *testProfiledArtist) ProfileResponseValue(profile Profile, joins objx.Map) interface{} {
+	switch profile {
+	case ProfileSummary:
+		return map[string]interface{}{"id": artist.Id}
+	case ProfileAdmin:
+		return *artist
+	}
+	return map[string]interface{}{"id": artist.Id, "name": artist.Name}
+}
+
+type testSummarizedAlbum struct {
+	Id    int
+	Title string
+}
+
+func (album *testSummarizedAlbum) ResponseValue(joins objx.Map) interface{} {
+	return album.Id
+}
+
+type testProfiledSong struct {
+	Artist *testProfiledArtist
+	Album  *testSummarizedAlbum
+}
+
+func TestCreateResponseProfiles(t *testing.T) {
+	song := &testProfiledSong{
+		Artist: &testProfiledArtist{Id: 1, Name: "Artist", Secret: "royalties"},
+		Album:  &testSummarizedAlbum{Id: 2, Title: "Album"},
+	}
+
+	response, err := CreateResponseWithOptions(song)
+	assert.NoError(t, err)
+	m := response.(objx.Map)
+	assert.Equal(t, map[string]interface{}{"id": 1, "name": "Artist"}, m["artist"])
+	assert.Equal(t, 2, m["album"])
+
+	response, err = CreateResponseWithOptions(song, WithProfile(ProfileSummary))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": 1}, response.(objx.Map)["artist"])
+
+	response, err = CreateResponseWithOptions(song, WithProfile(ProfileAdmin))
+	assert.NoError(t, err)
+	assert.Equal(t, "royalties", response.(objx.Map)["artist"].(objx.Map)["secret"])
+
+	joins := objx.Map{"album": map[string]interface{}{"profile": "full"}}
+	response, err = CreateResponseWithOptions(song, WithJoins(joins))
+	assert.NoError(t, err)
+	assert.Equal(t, "Album", response.(objx.Map)["album"].(objx.Map)["title"])
+
+	joins = objx.Map{"album": map[string]interface{}{"type": "full"}}
+	response, err = CreateResponseWithOptions(song, WithJoins(joins))
+	assert.NoError(t, err)
+	assert.Equal(t, "Album", response.(objx.Map)["album"].(objx.Map)["title"])
+
+	joins = objx.Map{"artist": map[string]interface{}{"profile": "admin"}}
+	_, err = CreateResponseWithOptions(song, WithJoins(joins))
+	if assert.IsType(t, &OptionError{}, err) {
+		assert.Equal(t, "artist", err.(*OptionError).Path)
+	}
+
+	response, err = CreateResponseWithOptions(song.Artist, WithProfile(ProfileSummary))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": 1}, response)
+}
+
+type testRating int
+
+func (rating testRating) ProfileResponseValue(profile Profile, joins objx.Map) interface{} {
+	if profile == ProfileSummary {
+		return int(rating)
+	}
+	return map[string]interface{}{"stars": int(rating), "max": 5}
+}
+
+type testRatedSong struct {
+	Title  string
+	Rating testRating
+}
+
+func TestCreateResponseProfiledScalarField(t *testing.T) {
+	song := testRatedSong{Title: "Song", Rating: 4}
+
+	response, err := CreateResponseWithOptions(song)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"stars": 4, "max": 5}, response.(objx.Map)["rating"])
+
+	response, err = CreateResponseWithOptions(song, WithProfile(ProfileSummary))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, response.(objx.Map)["rating"])
+}
@@ -0,0 +1,89 @@
+package web_responders
+
+import (
+	"reflect"
+)
+
+// A Renderer converts a value to the value used for it in a response.
+// The value it returns is converted in turn, the same as the return
+// value of a ResponseValueCreator.
+//
+// Renderers keep formatting that is shared between fields in one
+// place, without wrapping each field in a type that implements
+// ResponseValueCreator.  They can either be registered by name and
+// chosen per field with the "render" tag option:
+//
+//	web_responders.RegisterRenderer("money", func(value interface{}, options *web_responders.ResponseOptions) interface{} {
+//	    return fmt.Sprintf("%.2f", float64(value.(int))/100)
+//	})
+//
+//	type Product struct {
+//	    Price int `response:"price,render=money"`
+//	}
+//
+// or registered for a type, to be used for every value of that type,
+// wherever it appears in a response (including the response itself,
+// and embedded structs):
+//
+//	web_responders.RegisterTypeRenderer(decimal.Decimal{}, func(value interface{}, options *web_responders.ResponseOptions) interface{} {
+//	    return value.(decimal.Decimal).String()
+//	})
+type Renderer func(value interface{}, options *ResponseOptions) interface{}
+
+var (
+	// namedRenderers holds the renderers registered with
+	// RegisterRenderer.
+	namedRenderers = make(map[string]Renderer)
+
+	// typeRenderers holds the renderers registered with
+	// RegisterTypeRenderer.
+	typeRenderers = make(map[reflect.Type]Renderer)
+)
+
+// RegisterRenderer registers renderer under name, for use with the
+// "render" tag option.  Renderers must be registered before any
+// response uses a struct with a field that names them; a "render" tag
+// option naming a renderer that hasn't been registered results in a
+// *RendererError, which is a 500 Internal Server Error since it is a
+// mistake in the code rather than in the request.  This is not safe
+// to call while responses are being created, so it should be called
+// during startup.
+func RegisterRenderer(name string, renderer Renderer) {
+	namedRenderers[name] = renderer
+	resetStructPlans()
+}
+
+// RegisterTypeRenderer registers renderer for all values with the
+// same type as example (and pointers to them).  Type renderers take
+// precedence over the type's own methods (e.g. ResponseValueCreator
+// or fmt.Stringer) and over time formatting, but not over a field's
+// "render" tag option.  This is not safe to call while responses are
+// being created, so it should be called during startup.
+func RegisterTypeRenderer(example interface{}, renderer Renderer) {
+	typeRenderers[reflect.TypeOf(example)] = renderer
+	resetStructPlans()
+}
+
+// hasTypeRenderer returns whether or not a renderer has been
+// registered for t.
+func hasTypeRenderer(t reflect.Type) bool {
+	_, ok := typeRenderers[t]
+	return ok
+}
+
+// typeRenderer returns the renderer registered for the type of value,
+// or for the type value points to.
+func typeRenderer(value reflect.Value) (Renderer, reflect.Value, bool) {
+	if len(typeRenderers) == 0 || !value.IsValid() {
+		return nil, value, false
+	}
+	if renderer, ok := typeRenderers[value.Type()]; ok {
+		return renderer, value, true
+	}
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		if renderer, ok := typeRenderers[value.Type().Elem()]; ok {
+			return renderer, value.Elem(), true
+		}
+	}
+	return nil, value, false
+}
@@ -0,0 +1,89 @@
+package web_responders
+
+import (
+	"fmt"
+	"github.com/stretchr/objx"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type testPercent float64
+
+func TestCreateResponseRenderers(t *testing.T) {
+	RegisterRenderer("money", func(value interface{}, options *ResponseOptions) interface{} {
+		return fmt.Sprintf("$%.2f", float64(value.(int))/100)
+	})
+	RegisterTypeRenderer(testPercent(0), func(value interface{}, options *ResponseOptions) interface{} {
+		return fmt.Sprintf("%.0f%%", float64(value.(testPercent))*100)
+	})
+	defer func() {
+		delete(namedRenderers, "money")
+		delete(typeRenderers, reflect.TypeOf(testPercent(0)))
+		resetStructPlans()
+	}()
+
+	type product struct {
+		Price    int `response:"price,render=money"`
+		Count    int
+		Discount testPercent
+		Tax      *testPercent
+	}
+	tax := testPercent(0.05)
+	response, err := CreateResponseWithOptions(product{Price: 1250, Count: 2, Discount: 0.1, Tax: &tax})
+	assert.NoError(t, err)
+	assert.Equal(t, objx.Map{
+		"price":    "$12.50",
+		"count":    2,
+		"discount": "10%",
+		"tax":      "5%",
+	}, response)
+
+	response, err = CreateResponseWithOptions(testPercent(0.25))
+	assert.NoError(t, err)
+	assert.Equal(t, "25%", response)
+
+	type Percent = testPercent
+	type summary struct {
+		Percent
+		Count int
+	}
+	response, err = CreateResponseWithOptions(summary{Percent: 0.5, Count: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, objx.Map{"percent": "50%", "count": 3}, response)
+}
+
+func TestCreateResponseUnknownRenderer(t *testing.T) {
+	type unknown struct {
+		Price int `response:"price,render=unknown"`
+	}
+	type catalog struct {
+		Products []unknown
+	}
+	_, err := CreateResponseWithOptions(unknown{})
+	if assert.IsType(t, &RendererError{}, err) {
+		assert.Equal(t, "price", err.(*RendererError).Path)
+		assert.Equal(t, "unknown", err.(*RendererError).Name)
+		assert.Equal(t, http.StatusInternalServerError, err.(ResponseError).ResponseStatus())
+	}
+	_, err = CreateResponseWithOptions(catalog{Products: []unknown{{}}})
+	if assert.IsType(t, &RendererError{}, err) {
+		assert.Equal(t, "products.0.price", err.(*RendererError).Path)
+	}
+	structPlans.RLock()
+	_, cached := structPlans.plans[reflect.TypeOf(unknown{})]
+	structPlans.RUnlock()
+	assert.False(t, cached)
+
+	RegisterRenderer("unknown", func(value interface{}, options *ResponseOptions) interface{} {
+		return "rendered"
+	})
+	defer func() {
+		delete(namedRenderers, "unknown")
+		resetStructPlans()
+	}()
+	response, err := CreateResponseWithOptions(unknown{})
+	assert.NoError(t, err)
+	assert.Equal(t, objx.Map{"price": "rendered"}, response)
+}
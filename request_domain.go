@@ -0,0 +1,302 @@
+package web_responders
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// canonicalBaseURL is set by SetCanonicalBaseURL.
+	canonicalBaseURL string
+
+	// trustedProxies is set by SetTrustedProxies.
+	trustedProxies []*net.IPNet
+)
+
+// SetCanonicalBaseURL sets the base URL (e.g.
+// "https://api.radiobox.com" or "https://radiobox.com/api") that
+// RequestDomain returns for every request, ignoring the request's
+// host and any forwarding headers.  An empty base URL (the default)
+// means that the domain will be worked out from each request.  This
+// is not safe to call while responses are being created, so it should
+// be called during startup.
+func SetCanonicalBaseURL(baseURL string) error {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL != "" && !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return errors.New("Canonical base URL must start with http:// or https://")
+	}
+	canonicalBaseURL = baseURL
+	return nil
+}
+
+// SetTrustedProxies sets the proxies whose forwarding headers are
+// used by RequestDomain.  Each proxy may be an IP address (e.g.
+// "10.0.0.1") or a CIDR range (e.g. "10.0.0.0/8").  Forwarding headers
+// on requests from anywhere else are ignored, since clients could use
+// them to change the links in our responses.  By default, no proxies
+// are trusted.  This is not safe to call while responses are being
+// created, so it should be called during startup.
+func SetTrustedProxies(proxies ...string) error {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.ContainsRune(proxy, '/') {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return errors.New("Invalid proxy address: " + proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return errors.New("Invalid proxy range: " + proxy)
+		}
+		networks = append(networks, network)
+	}
+	trustedProxies = networks
+	return nil
+}
+
+// isTrustedProxy returns whether or not remoteAddr (in the format of
+// http.Request.RemoteAddr, or a node from a forwarding header) is one
+// of the trusted proxies.
+func isTrustedProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(remoteAddr, "["), "]")
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestDomain returns the scheme, host and path prefix (e.g.
+// "https://radiobox.com/api") that the client used to make request,
+// which is prepended to the locations and links in our responses.
+//
+// If a canonical base URL has been set (see SetCanonicalBaseURL), it
+// is always used.  Otherwise, the domain comes from the request
+// itself; if the request came from a trusted proxy (see
+// SetTrustedProxies), the Forwarded header (RFC 7239) or the
+// X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Prefix headers
+// are used in place of the request's own values.  Proxies append to
+// these headers, so they are read from right to left, skipping the
+// values added for requests from other trusted proxies; anything to
+// the left of the value added for the client's request was sent by
+// the client, and is ignored.  A forwarded host that isn't a plain
+// host[:port] is ignored as well.
+//
+// The X-Forwarded-For header tells us how many trusted proxies the
+// request went through, and every one of them has to append its own
+// value to each of the other X-Forwarded-* headers; otherwise, those
+// headers are ignored.  Deployments with more than one proxy where
+// that isn't the case (e.g. only the outermost proxy sets
+// X-Forwarded-Proto) should use the Forwarded header instead, since
+// each of its elements says which proxy it came from.
+func RequestDomain(request *http.Request) string {
+	if canonicalBaseURL != "" {
+		return canonicalBaseURL
+	}
+
+	proto := "http"
+	if request.TLS != nil {
+		proto += "s"
+	}
+	host := request.Host
+	prefix := ""
+
+	if isTrustedProxy(request.RemoteAddr) {
+		forwarded := trustedForwarded(request.Header.Get("Forwarded"))
+		hops := trustedHops(request.Header.Get("X-Forwarded-For"))
+		if value := forwarded["proto"]; value != "" {
+			proto = value
+		} else if value := forwardedValue(request.Header.Get("X-Forwarded-Proto"), hops); value != "" {
+			proto = value
+		}
+		if value := forwarded["host"]; value != "" {
+			host = value
+		} else if value := forwardedValue(request.Header.Get("X-Forwarded-Host"), hops); value != "" {
+			host = value
+		}
+		prefix = forwardedValue(request.Header.Get("X-Forwarded-Prefix"), hops)
+	}
+
+	proto = strings.ToLower(proto)
+	if proto != "http" && proto != "https" {
+		proto = "http"
+	}
+	if !validHost(host) {
+		host = request.Host
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	if !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, " \t?#") {
+		prefix = ""
+	}
+	return proto + "://" + host + prefix
+}
+
+// splitHeader splits a header into its comma separated values,
+// ignoring commas in quoted strings.
+func splitHeader(header string) []string {
+	var values []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(header); i++ {
+		switch header[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case ',':
+			if !quoted {
+				values = append(values, strings.TrimSpace(header[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if value := strings.TrimSpace(header[start:]); value != "" || len(values) > 0 {
+		values = append(values, value)
+	}
+	return values
+}
+
+// trustedHops returns the number of trusted proxies at the end of an
+// X-Forwarded-For header.  Each proxy appends the address it received
+// the request from, so these are the proxies between the client and
+// the proxy that sent us the request.
+func trustedHops(header string) int {
+	nodes := splitHeader(header)
+	hops := 0
+	for i := len(nodes) - 1; i >= 0 && isTrustedProxy(nodes[i]); i-- {
+		hops++
+	}
+	return hops
+}
+
+// forwardedValue returns the value of an X-Forwarded-* header that was
+// added by the trusted proxy closest to the client, which is hops
+// values from the end.  Values to the left of it were sent by the
+// client, so they can't be trusted.  This relies on each trusted
+// proxy appending one value to the header; if there are fewer values
+// than proxies, some proxy replaced the header or passed it on as it
+// was, so there is no telling which value (if any) came from the
+// proxy closest to the client, and the header is ignored.
+func forwardedValue(header string, hops int) string {
+	values := splitHeader(header)
+	index := len(values) - 1 - hops
+	if index < 0 {
+		return ""
+	}
+	return values[index]
+}
+
+// trustedForwarded returns the parameters of the element of a
+// Forwarded header that was added by the trusted proxy closest to the
+// client.  The elements are read from right to left, skipping those
+// that were added for requests from other trusted proxies; elements
+// to the left of the one that is used were sent by the client.
+func trustedForwarded(header string) map[string]string {
+	elements := splitHeader(header)
+	for i := len(elements) - 1; i >= 0; i-- {
+		params := parseForwardedElement(elements[i])
+		if i == 0 || !isTrustedProxy(params["for"]) {
+			return params
+		}
+	}
+	return nil
+}
+
+// parseForwardedElement parses an element of a Forwarded header into
+// a map of lowercased parameter names to values.
+func parseForwardedElement(element string) map[string]string {
+	params := make(map[string]string)
+	for _, pair := range strings.Split(element, ";") {
+		index := strings.IndexRune(pair, '=')
+		if index == -1 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(pair[:index]))
+		params[name] = unquoteHeaderParam(strings.TrimSpace(pair[index+1:]))
+	}
+	return params
+}
+
+// unquoteHeaderParam returns the value of a header parameter, which
+// may be a quoted string with backslash escapes.
+func unquoteHeaderParam(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	value = value[1 : len(value)-1]
+	unquoted := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		unquoted = append(unquoted, value[i])
+	}
+	return string(unquoted)
+}
+
+// validHost returns whether or not host is a valid host[:port], i.e.
+// a host name, an IPv4 address or a bracketed IPv6 address, with an
+// optional port.  Anything else (e.g. a host with a path, query or
+// user info) could be used to change the links in our responses.
+func validHost(host string) bool {
+	name := host
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		name = host[1 : len(host)-1]
+		return strings.ContainsRune(name, ':') && net.ParseIP(name) != nil
+	}
+	if strings.ContainsRune(host, ':') {
+		var port string
+		var err error
+		name, port, err = net.SplitHostPort(host)
+		if err != nil || !validPort(port) {
+			return false
+		}
+		if strings.HasPrefix(host, "[") {
+			return strings.ContainsRune(name, ':') && net.ParseIP(name) != nil
+		}
+	}
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, r := range label {
+			switch {
+			case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			case r == '-' || r == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validPort returns whether or not port is a valid port number.
+func validPort(port string) bool {
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number <= 65535 && strings.Trim(port, "0123456789") == ""
+}
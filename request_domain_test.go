@@ -0,0 +1,104 @@
+package web_responders
+
+import (
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func newDomainRequest(remoteAddr string, headers map[string]string) *http.Request {
+	request, _ := http.NewRequest("GET", "http://internal:8080/songs", nil)
+	request.RemoteAddr = remoteAddr
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	return request
+}
+
+func TestRequestDomain(t *testing.T) {
+	defer SetTrustedProxies()
+	assert.NoError(t, SetTrustedProxies("10.0.0.0/8", "192.168.1.1"))
+
+	forwarded := map[string]string{
+		"X-Forwarded-Proto":  "https, http",
+		"X-Forwarded-For":    "1.2.3.4, 10.0.0.2",
+		"X-Forwarded-Host":   "radiobox.com, internal",
+		"X-Forwarded-Prefix": "/api/, /",
+	}
+	assert.Equal(t, "http://internal:8080", RequestDomain(newDomainRequest("8.8.8.8:1234", forwarded)))
+	assert.Equal(t, "https://radiobox.com/api", RequestDomain(newDomainRequest("10.1.2.3:1234", forwarded)))
+	assert.Equal(t, "https://radiobox.com/api", RequestDomain(newDomainRequest("192.168.1.1:1234", forwarded)))
+
+	// Only the outermost proxy set these, so there's no telling
+	// whether they came from the proxy or the client.
+	request := newDomainRequest("10.1.2.3:1234", map[string]string{
+		"X-Forwarded-For":   "1.2.3.4, 10.0.0.2",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "radiobox.com",
+	})
+	assert.Equal(t, "http://internal:8080", RequestDomain(request))
+
+	request = newDomainRequest("10.1.2.3:1234", map[string]string{
+		"Forwarded":        `for=1.2.3.4;proto=https;host="radiobox.com", for=10.0.0.2`,
+		"X-Forwarded-Host": "ignored.com",
+	})
+	assert.Equal(t, "https://radiobox.com", RequestDomain(request))
+
+	request = newDomainRequest("10.1.2.3:1234", map[string]string{
+		"X-Forwarded-Proto": "javascript",
+		"X-Forwarded-Host":  "evil.com/path",
+	})
+	assert.Equal(t, "http://internal:8080", RequestDomain(request))
+
+	request = newDomainRequest("10.1.2.3:1234", map[string]string{
+		"Forwarded": `host=evil.com;proto=https, for=1.2.3.4;host=radiobox.com`,
+	})
+	assert.Equal(t, "http://radiobox.com", RequestDomain(request))
+
+	request = newDomainRequest("8.8.8.8:1234", nil)
+	request.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "https://internal:8080", RequestDomain(request))
+
+	assert.Error(t, SetTrustedProxies("not an address"))
+}
+
+func TestRequestDomainCanonicalBaseURL(t *testing.T) {
+	defer SetCanonicalBaseURL("")
+	assert.NoError(t, SetCanonicalBaseURL("https://radiobox.com/api/"))
+	assert.Equal(t, "https://radiobox.com/api", RequestDomain(newDomainRequest("8.8.8.8:1234", nil)))
+	assert.Error(t, SetCanonicalBaseURL("radiobox.com"))
+}
+
+func TestRequestDomainClientForwardedHeaders(t *testing.T) {
+	defer SetTrustedProxies()
+	assert.NoError(t, SetTrustedProxies("10.0.0.0/8"))
+
+	// The client sends its own X-Forwarded-* headers (including a
+	// trusted address in X-Forwarded-For), and the proxy appends its
+	// values to them.
+	request := newDomainRequest("10.1.2.3:1234", map[string]string{
+		"X-Forwarded-For":    "10.0.0.5, 1.2.3.4",
+		"X-Forwarded-Proto":  "http, https",
+		"X-Forwarded-Host":   "evil.com, radiobox.com",
+		"X-Forwarded-Prefix": "/evil, /api",
+	})
+	assert.Equal(t, "https://radiobox.com/api", RequestDomain(request))
+}
+
+func TestRequestDomainInvalidHosts(t *testing.T) {
+	defer SetTrustedProxies()
+	assert.NoError(t, SetTrustedProxies("10.0.0.0/8"))
+
+	for _, host := range []string{
+		"radiobox.com?x=1", "radiobox.com#x", `"radiobox.com,evil.com"`, `"radiobox.com;x"`,
+		"radiobox.com:99999", "radiobox.com:", "user@radiobox.com", "::1", "radio box.com",
+	} {
+		request := newDomainRequest("10.1.2.3:1234", map[string]string{"Forwarded": "host=" + host})
+		assert.Equal(t, "http://internal:8080", RequestDomain(request), host)
+	}
+	for _, host := range []string{"radiobox.com", "radiobox.com:8443", "127.0.0.1:80", "[::1]:8080", "[::1]"} {
+		request := newDomainRequest("10.1.2.3:1234", map[string]string{"X-Forwarded-Host": host})
+		assert.Equal(t, "http://"+host, RequestDomain(request), host)
+	}
+}
@@ -4,7 +4,8 @@
 package web_responders
 
 import (
-	"errors"
+	"encoding"
+	"encoding/json"
 	"fmt"
 	"github.com/Radiobox/web_request_readers"
 	"github.com/stretchr/goweb"
@@ -12,6 +13,7 @@ import (
 	"github.com/stretchr/objx"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -19,6 +21,16 @@ import (
 // database/sql has nullable values which all have the same prefix.
 const SqlNullablePrefix = "Null"
 
+const (
+	// callbackParameter is the query parameter goweb uses for
+	// callback (JSONP) requests.
+	callbackParameter = "callback"
+
+	// Headers used to send meta values for a MetaHeaderCodec.
+	codeHeader          = "X-Response-Code"
+	notificationsHeader = "X-Notifications"
+)
+
 // CreateResponse takes a value to be used as a response and attempts
 // to generate a value to respond with, based on struct tag and
 // interface matching.
@@ -28,41 +40,204 @@ const SqlNullablePrefix = "Null"
 // yet.
 //
 // Struct values will be converted to a map[string]interface{}.  Each
-// field will be assigned a key - the "request" tag's value if it
-// exists, or the "response" tag's value if it exists, or just the
-// lowercase field name if neither tag exists.  A value of "-" for the
-// key (i.e. the value of a request or response tag) will result in
-// the field being skipped.
+// field will be assigned a key using ParseResponseTag - the name from
+// the "response" tag if it exists, or the "db" tag, or the name from
+// the "json" tag, or the field name converted with the current
+// NamingStrategy (see SetNamingStrategy).  A value of "-" for the key
+// will result in the field being skipped.  As with encoding/json, an
+// "omitempty" tag option (e.g. `json:"name,omitempty"`) skips the
+// field when its value is empty (see isEmptyValue for details,
+// including IsZeroer support), and a "string" tag option renders
+// numbers and bools as strings.  An "omitnil" tag option skips the
+// field when its response value would be nil - for example, a null
+// database/sql Null* value, or a nil NilResponder that returns nil
+// from NilResponseValue.
+//
+// Links (URL values, Link values, and fields with the "link" tag
+// option) that are relative to the server root path have the domain
+// prepended to them.  Other strings are never changed, even if they
+// start with a "/".
+//
+// Times and durations (time.Time, time.Duration, pointers to them,
+// and nullable times) are converted using the TimeFormat,
+// TimeLocation and DurationFormat of the ResponseOptions, so they are
+// formatted the same way no matter where they appear in a response.
+//
+// A "render" tag option (e.g. `response:"price,render=money"`) uses
+// the named Renderer for the field, and values of a type with a
+// registered Renderer always use it; see RegisterRenderer and
+// RegisterTypeRenderer.
 //
 // CreateResponse will skip parsing any sub-elements of a response
 // (i.e. entries in a slice or map, or fields of a struct) that
 // implement the ResponseValueCreator, and instead just use the return
-// value of their ResponseValue() method.
+// value of their ResponseValue() method, unless their Profile is
+// ProfileFull.  Values that implement ProfileResponseValueCreator use
+// the response value for their Profile instead.
+//
+// The optionList may contain, in any order, the joins options (an
+// objx.Map), the constructor used to wrap embedded collections (a
+// func(interface{}, interface{}) interface{}), the domain to prepend
+// to links (a string; see URL), a MaxDepth, a CyclePolicy, and any
+// ResponseOption values.
+//
+// CreateResponse panics if the response can't be created (e.g. when
+// the options are malformed).  CreateResponseWithOptions returns the
+// problem as a ResponseError instead, so it should be used whenever
+// any of the options come from the client.
+//
+// Deprecated: the positional optionList can't be checked by the
+// compiler and can't be extended; use CreateResponseWithOptions.
 func CreateResponse(data interface{}, optionList ...interface{}) interface{} {
-	if err, ok := data.(error); ok {
-		return err.Error()
-	}
-
-	// Parse options
-	var (
-		options     objx.Map
-		constructor func(interface{}, interface{}) interface{}
-		domain      string
-	)
-	switch len(optionList) {
-	case 3:
-		domain = optionList[2].(string)
-		fallthrough
-	case 2:
-		constructor = optionList[1].(func(interface{}, interface{}) interface{})
-		fallthrough
-	case 1:
-		options = optionList[0].(objx.Map)
-	}
-	return createResponse(data, false, options, constructor, domain)
+	opts, err := positionalOptions(optionList)
+	if err != nil {
+		panic(err)
+	}
+	response, err := CreateResponseWithOptions(data, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return response
+}
+
+// positionalOptions converts the optionList of CreateResponse to
+// ResponseOption values.
+func positionalOptions(optionList []interface{}) ([]ResponseOption, error) {
+	opts := make([]ResponseOption, 0, len(optionList))
+	for i, option := range optionList {
+		switch src := option.(type) {
+		case nil:
+		case objx.Map:
+			opts = append(opts, WithJoins(src))
+		case map[string]interface{}:
+			opts = append(opts, WithJoins(objx.Map(src)))
+		case func(interface{}, interface{}) interface{}:
+			opts = append(opts, WithConstructor(src))
+		case string:
+			opts = append(opts, WithDomain(src))
+		case MaxDepth:
+			opts = append(opts, WithMaxDepth(src))
+		case CyclePolicy:
+			opts = append(opts, WithCyclePolicy(src))
+		case ResponseOption:
+			opts = append(opts, src)
+		default:
+			return nil, &OptionError{Message: fmt.Sprintf("unsupported option of type %T at position %d", option, i)}
+		}
+	}
+	return opts, nil
+}
+
+// responseState holds the values that are shared by all of the
+// helpers used while creating a single response.
+type responseState struct {
+	options *ResponseOptions
+
+	// path is the list of keys leading to the value that is
+	// currently being created, for error messages.
+	path []string
+
+	// visiting holds the pointers and maps that are currently being
+	// created, to detect cycles.
+	visiting map[visitKey]bool
+}
+
+// A visitKey identifies a value for cycle detection.  The type is
+// needed because a pointer to a struct and a pointer to its first
+// field have the same address, and the length is needed because a
+// slice and a shorter slice of the same array start at the same
+// address.
+type visitKey struct {
+	pointer   uintptr
+	valueType reflect.Type
+	length    int
+}
+
+// push adds key to the path, returning a *DepthError if the path is
+// now longer than the maximum depth.  The joins are the joins options
+// for the value at key; if there are any, the value was included
+// because the client asked for it.
+func (state *responseState) push(key string, joins objx.Map) error {
+	state.path = append(state.path, key)
+	if maxDepth := state.options.MaxDepth; maxDepth > 0 && len(state.path) > int(maxDepth) {
+		err := &DepthError{Path: state.pathString(), MaxDepth: maxDepth, Joined: joins != nil}
+		state.pop()
+		return err
+	}
+	return nil
+}
+
+// cycleKey returns the visitKey for value, if it is a value that
+// could contain itself: a pointer, a map, or a slice that isn't
+// empty.
+func cycleKey(value reflect.Value) (visitKey, bool) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map:
+		if !value.IsNil() {
+			return visitKey{value.Pointer(), value.Type(), 0}, true
+		}
+	case reflect.Slice:
+		if value.Len() > 0 {
+			return visitKey{value.Pointer(), value.Type(), value.Len()}, true
+		}
+	}
+	return visitKey{}, false
 }
 
-func createResponse(data interface{}, isSubResponse bool, options objx.Map, constructor func(interface{}, interface{}) interface{}, domain string) interface{} {
+func (state *responseState) pop() {
+	state.path = state.path[:len(state.path)-1]
+}
+
+func (state *responseState) pathString() string {
+	return strings.Join(state.path, ".")
+}
+
+// fieldSelected returns whether or not the field name, within the
+// value currently being created, is selected by the Fields option.
+// Slice indexes in the path are ignored.
+func (state *responseState) fieldSelected(name string) bool {
+	if len(state.options.Fields) == 0 {
+		return true
+	}
+	keys := make([]string, 0, len(state.path)+1)
+	for _, key := range state.path {
+		if _, err := strconv.Atoi(key); err != nil {
+			keys = append(keys, key)
+		}
+	}
+	return state.options.fieldSelected(strings.Join(append(keys, name), "."))
+}
+
+// subOptions looks up the options for the sub-value at key, falling
+// back to the "*" key.
+func (state *responseState) subOptions(options objx.Map, key string) (objx.Map, error) {
+	if options == nil {
+		return nil, nil
+	}
+	var subOptionsValue *objx.Value
+	if options.Has(key) {
+		subOptionsValue = options.Get(key)
+	} else if options.Has("*") {
+		subOptionsValue = options.Get("*")
+	}
+	switch {
+	case subOptionsValue == nil:
+		return nil, nil
+	case subOptionsValue.IsMSI():
+		return objx.Map(subOptionsValue.MSI()), nil
+	case subOptionsValue.IsObjxMap():
+		return subOptionsValue.ObjxMap(), nil
+	}
+	return nil, &OptionError{
+		Path:    strings.Join(append(state.path, key), "."),
+		Message: fmt.Sprintf("expected an object, but got %v", subOptionsValue.Data()),
+	}
+}
+
+func createResponse(data interface{}, isSubResponse bool, options objx.Map, state *responseState) (interface{}, error) {
+	if renderer, rendered, ok := typeRenderer(reflect.ValueOf(data)); ok {
+		return createResponse(renderer(rendered.Interface(), state.options), isSubResponse, options, state)
+	}
 
 	// LazyLoad with options
 	if lazyLoader, ok := data.(LazyLoader); ok {
@@ -74,198 +249,328 @@ func createResponse(data interface{}, isSubResponse bool, options objx.Map, cons
 		responseData = responseCreator.ResponseObject()
 	}
 
+	if nullValue, ok := nullableValue(responseData); ok {
+		if nullValue == nil {
+			return nil, nil
+		}
+		return createResponse(nullValue, isSubResponse, options, state)
+	}
+
 	value := reflect.ValueOf(responseData)
+	if key, ok := cycleKey(value); ok {
+		if state.visiting[key] {
+			if state.options.Cycles == CyclesAsReferences {
+				if reference, ok := state.reference(data, reflect.Indirect(value)); ok {
+					return reference, nil
+				}
+			}
+			return nil, &CycleError{Path: state.pathString(), Type: value.Type()}
+		}
+		state.visiting[key] = true
+		defer delete(state.visiting, key)
+	}
 	if value.Kind() == reflect.Ptr {
 		value = value.Elem()
 	}
+	if value.IsValid() {
+		if formatted, ok := state.formatTime(value); ok {
+			return formatted, nil
+		}
+	}
 	switch value.Kind() {
 	case reflect.Struct:
-		data = createStructResponse(value, options, constructor, domain)
+		response, err := createStructResponse(value, options, state)
+		if err != nil {
+			return nil, err
+		}
+		if object, ok := response.(objx.Map); ok && isSubResponse && state.options.ResourceFormatter != nil {
+			return state.options.ResourceFormatter(data, object), nil
+		}
+		return response, nil
 	case reflect.Slice, reflect.Array:
-		data = createSliceResponse(value, options, constructor, domain)
-		if options != nil && isSubResponse {
-			data = constructor(data, value)
+		response, err := createSliceResponse(value, options, state)
+		if err != nil {
+			return nil, err
+		}
+		if options != nil && isSubResponse && state.options.Constructor != nil {
+			return state.options.Constructor(response, value), nil
 		}
+		return response, nil
 	case reflect.Map:
-		data = createMapResponse(value, options, constructor, domain)
+		return createMapResponse(value, options, state)
 	case reflect.String:
-		if domain != "" {
-			// Prepend the domain to all links
-			strPtr := new(string)
-			strVal := reflect.ValueOf(strPtr).Elem()
-			strVal.Set(value.Convert(strVal.Type()))
-			str := *strPtr
-			if str != "" && str[0] == '/' {
-				str = domain + str
-			}
-			data = str
-		} else {
-			data = responseData
+		if value.Type() == urlType {
+			return URL(value.String()).Absolute(state.options.Domain), nil
 		}
-	default:
-		data = responseData
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil, &UnsupportedKindError{Path: state.pathString(), Type: value.Type()}
 	}
-	return data
+	return responseData, nil
 }
 
-// createNullableDbResponse checks for "database/sql".Null* types, or
-// anything with a similar structure, and pulls out the underlying
-// value.  For example:
-//
-//     type NullInt struct {
-//         Int int
-//         Valid bool
-//     }
-//
-// If Valid is false, this function will return nil; otherwise, it
-// will return the value of the Int field.
-func createNullableDbResponse(value reflect.Value, valueType reflect.Type) (interface{}, error) {
-	typeName := valueType.Name()
-	if strings.HasPrefix(typeName, SqlNullablePrefix) {
-		fieldName := typeName[len(SqlNullablePrefix):]
-		val := value.FieldByName(fieldName)
-		isNotNil := value.FieldByName("Valid")
-		if val.IsValid() && isNotNil.IsValid() {
-			// We've found a nullable type
-			if isNotNil.Interface().(bool) {
-				return val.Interface(), nil
-			} else {
-				return nil, nil
-			}
+// createMapResponse is a helper for generating a response value from
+// a value of type map.  The response value is always a
+// map[string]interface{}, regardless of the original map type; see
+// mapKeyString for details about how keys are converted to strings.
+// If two keys convert to the same string, a *MapKeyError is returned
+// rather than letting one value silently replace the other.
+func createMapResponse(value reflect.Value, options objx.Map, state *responseState) (interface{}, error) {
+	response := make(map[string]interface{}, value.Len())
+	for _, key := range value.MapKeys() {
+		keyStr, err := mapKeyString(key)
+		if err != nil {
+			return nil, &MapKeyError{Path: state.pathString(), Err: err}
+		}
+		if _, ok := response[keyStr]; ok {
+			return nil, &MapKeyError{Path: state.pathString(), Key: keyStr}
+		}
+		elementOptions, err := state.subOptions(options, keyStr)
+		if err != nil {
+			return nil, err
+		}
+		if err := state.push(keyStr, elementOptions); err != nil {
+			return nil, err
+		}
+		response[keyStr], err = createResponseValue(value.MapIndex(key), elementOptions, state)
+		state.pop()
+		if err != nil {
+			return nil, err
 		}
 	}
-	return nil, errors.New("No Nullable DB value found")
+	return response, nil
 }
 
-// createMapResponse is a helper for generating a response value from
-// a value of type map.
-func createMapResponse(value reflect.Value, options objx.Map, constructor func(interface{}, interface{}) interface{}, domain string) interface{} {
-	response := reflect.MakeMap(value.Type())
-	for _, key := range value.MapKeys() {
-		var elementOptions objx.Map
-		keyStr := key.Interface().(string)
-		if options != nil {
-			var elementOptionsValue *objx.Value
-			if options.Has(keyStr) {
-				elementOptionsValue = options.Get(keyStr)
-			} else if options.Has("*") {
-				elementOptionsValue = options.Get("*")
-			}
-			if elementOptionsValue.IsMSI() {
-				elementOptions = objx.Map(elementOptionsValue.MSI())
-			} else if elementOptionsValue.IsObjxMap() {
-				elementOptions = elementOptionsValue.ObjxMap()
-			} else {
-				panic("Don't know what to do with option")
-			}
+// mapKeyString converts a map key to a string.  Keys of the types
+// that encoding/json supports are converted the same way, in the same
+// order: keys of a string kind are used as-is, keys that implement
+// encoding.TextMarshaler use MarshalText, and keys of an integer kind
+// are formatted as base 10 numbers.  Any other key (which
+// encoding/json would refuse) uses String if it implements
+// fmt.Stringer, or is formatted using the fmt package.
+func mapKeyString(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.Interface {
+		key = key.Elem()
+	}
+	if !key.IsValid() {
+		return fmt.Sprint(nil), nil
+	}
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+	keyInter := key.Interface()
+	if marshaler, ok := keyInter.(encoding.TextMarshaler); ok {
+		if key.Kind() == reflect.Ptr && key.IsNil() {
+			return "", nil
 		}
-		itemResponse := createResponseValue(value.MapIndex(key), elementOptions, constructor, domain)
-		response.SetMapIndex(key, reflect.ValueOf(itemResponse))
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
 	}
-	return response.Interface()
+	if stringer, ok := keyInter.(fmt.Stringer); ok {
+		return stringer.String(), nil
+	}
+	return fmt.Sprint(keyInter), nil
 }
 
 // createSliceResponse is a helper for generating a response value
 // from a value of type slice.
-func createSliceResponse(value reflect.Value, options objx.Map, constructor func(interface{}, interface{}) interface{}, domain string) interface{} {
+func createSliceResponse(value reflect.Value, options objx.Map, state *responseState) (interface{}, error) {
 	response := make([]interface{}, 0, value.Len())
 	for i := 0; i < value.Len(); i++ {
-		element := value.Index(i)
-		response = append(response, createResponseValue(element, options, constructor, domain))
-	}
-	return response
-}
-
-func ResponseTag(field reflect.StructField) string {
-	var name string
-	if name = field.Tag.Get("response"); name != "" {
-		return name
-	}
-	if field.Name != "Id" {
-		if name = field.Tag.Get("db"); name != "" && name != "-" {
-			return name
+		if err := state.push(strconv.Itoa(i), options); err != nil {
+			return nil, err
+		}
+		element, err := createResponseValue(value.Index(i), options, state)
+		state.pop()
+		if err != nil {
+			return nil, err
 		}
+		response = append(response, element)
 	}
-	return strings.ToLower(field.Name)
+	return response, nil
 }
 
 // createStructResponse is a helper for generating a response value
 // from a value of type struct.
-func createStructResponse(value reflect.Value, options objx.Map, constructor func(interface{}, interface{}) interface{}, domain string) interface{} {
-	structType := value.Type()
-
-	// Support "database/sql".Null* types, and any other types
-	// matching that structure
-	if v, err := createNullableDbResponse(value, structType); err == nil {
-		return v
+//
+// "database/sql".Null* types, and any other types matching that
+// structure, are treated as the underlying value.  For example:
+//
+//     type NullInt struct {
+//         Int int
+//         Valid bool
+//     }
+//
+// If Valid is false, the response value will be nil; otherwise, it
+// will be the value of the Int field.  Nullable times (e.g.
+// sql.NullTime) are formatted the same as any other time.
+//
+// Embedded structs have their fields merged into the response.  Any
+// other embedded value (or an embedded struct that has its own
+// special response value, e.g. a Null* type) is added using the
+// field's key, the same as a regular field.  Nil embedded pointers
+// are skipped.
+//
+// The details of each struct type's fields are only parsed once; see
+// structPlanFor.
+func createStructResponse(value reflect.Value, options objx.Map, state *responseState) (interface{}, error) {
+	plan, err := structPlanFor(value.Type())
+	if rendererErr, ok := err.(*RendererError); ok {
+		path := rendererErr.Path
+		if len(state.path) > 0 {
+			path = state.pathString() + "." + path
+		}
+		return nil, &RendererError{Path: path, Name: rendererErr.Name}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if plan.nullable {
+		if !value.Field(plan.nullValid).Bool() {
+			return nil, nil
+		}
+		nullValue := value.Field(plan.nullValue)
+		if formatted, ok := state.formatTime(nullValue); ok {
+			return formatted, nil
+		}
+		return nullValue.Interface(), nil
 	}
 
-	response := make(objx.Map)
-
-	for i := 0; i < value.NumField(); i++ {
-		fieldType := structType.Field(i)
-		fieldValue := value.Field(i)
+	response := make(objx.Map, len(plan.fields))
+	for _, field := range plan.fields {
+		fieldValue := value.FieldByIndex(field.index)
 
-		if fieldType.Anonymous {
-			embeddedResponse := CreateResponse(fieldValue.Interface(), options, constructor, domain).(objx.Map)
-			for key, value := range embeddedResponse {
+		if field.embedded {
+			if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+				continue
+			}
+			embeddedResponse, err := createResponse(fieldValue.Interface(), false, options, state)
+			if err != nil {
+				return nil, err
+			}
+			var embeddedMap map[string]interface{}
+			switch src := embeddedResponse.(type) {
+			case objx.Map:
+				embeddedMap = src
+			case map[string]interface{}:
+				embeddedMap = src
+			default:
+				if _, ok := response[field.name]; !ok {
+					response[field.name] = embeddedResponse
+				}
+				continue
+			}
+			for key, value := range embeddedMap {
 				// Don't overwrite values from the base struct
 				if _, ok := response[key]; !ok {
 					response[key] = value
 				}
 			}
-		} else if unicode.IsUpper(rune(fieldType.Name[0])) {
-			name := ResponseTag(fieldType)
-			switch name {
-			case "-":
+			continue
+		}
+		if field.promoted {
+			if _, ok := response[field.name]; ok {
 				continue
-			default:
-				var subOptions objx.Map
-				if options != nil && (options.Has(name) || options.Has("*")) {
-					var subOptionsValue *objx.Value
-					if options.Has(name) {
-						subOptionsValue = options.Get(name)
-					} else {
-						subOptionsValue = options.Get("*")
-					}
-					if subOptionsValue.IsMSI() {
-						subOptions = objx.Map(subOptionsValue.MSI())
-					} else if subOptionsValue.IsObjxMap() {
-						subOptions = subOptionsValue.ObjxMap()
-					} else {
-						panic("Don't know what to do with option")
-					}
-				}
-				response[name] = createResponseValue(fieldValue, subOptions, constructor, domain)
 			}
 		}
+		if !state.fieldSelected(field.name) {
+			continue
+		}
+		if field.omitEmpty && isEmptyValue(fieldValue) {
+			continue
+		}
+		if field.scalar && !field.link && field.renderer == nil {
+			response[field.name] = stringOption(field, fieldValue.Interface())
+			continue
+		}
+
+		subOptions, err := state.subOptions(options, field.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := state.push(field.name, subOptions); err != nil {
+			return nil, err
+		}
+		var responseValue interface{}
+		if field.renderer != nil {
+			responseValue, err = createResponse(field.renderer(fieldValue.Interface(), state.options), true, subOptions, state)
+		} else {
+			responseValue, err = createResponseValue(fieldValue, subOptions, state)
+		}
+		state.pop()
+		if err != nil {
+			return nil, err
+		}
+		if field.omitNil && responseValue == nil {
+			continue
+		}
+		if field.link {
+			responseValue = state.linkValue(responseValue)
+		}
+		response[field.name] = stringOption(field, responseValue)
 	}
-	return response
+	return response, nil
+}
+
+// stringOption converts bool and numeric response values to strings
+// for fields with the "string" tag option.
+func stringOption(field fieldPlan, responseValue interface{}) interface{} {
+	if !field.asString || responseValue == nil {
+		return responseValue
+	}
+	switch reflect.ValueOf(responseValue).Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(responseValue)
+	}
+	return responseValue
 }
 
 // createResponseValue is a helper for generating a response value for
 // a single value in a response object.
-func createResponseValue(value reflect.Value, options objx.Map, constructor func(interface{}, interface{}) interface{}, domain string) (responseValue interface{}) {
+func createResponseValue(value reflect.Value, options objx.Map, state *responseState) (interface{}, error) {
 	if value.Kind() == reflect.Ptr && !value.Elem().IsValid() {
-		responseValue = nil
 		if nilResponder, ok := value.Interface().(NilResponder); ok {
-			responseValue = nilResponder.NilResponseValue()
+			return nilResponder.NilResponseValue(), nil
 		}
-	} else if options.Get("type").Str() != "full" {
+		return nil, nil
+	}
+	if renderer, rendered, ok := typeRenderer(value); ok {
+		return createResponse(renderer(rendered.Interface(), state.options), true, options, state)
+	}
+	if formatted, ok := state.formatTime(value); ok {
+		return formatted, nil
+	}
+	if _, ok := nullableValue(value.Interface()); ok {
+		return createResponse(value.Interface(), true, options, state)
+	}
+	profile, err := state.profile(options)
+	if err != nil {
+		return nil, err
+	}
+	if source, ok := value.Interface().(ProfileResponseValueCreator); ok {
+		return createResponse(source.ProfileResponseValue(profile, options), true, options, state)
+	}
+	if profile != ProfileFull {
 		switch source := value.Interface().(type) {
+		case OptionsResponseValueCreator:
+			return createResponse(source.ResponseValueWithOptions(options, state.options), true, options, state)
 		case ResponseValueCreator:
-			responseValue = createResponse(source.ResponseValue(options), true, options, constructor, domain)
+			return createResponse(source.ResponseValue(options), true, options, state)
 		case fmt.Stringer:
-			responseValue = createResponse(source.String(), true, options, constructor, domain)
+			return createResponse(source.String(), true, options, state)
 		case error:
-			responseValue = createResponse(source.Error(), true, options, constructor, domain)
-		default:
-			responseValue = createResponse(value.Interface(), true, options, constructor, domain)
+			return createResponse(source.Error(), true, options, state)
 		}
-	} else {
-		responseValue = createResponse(value.Interface(), true, options, constructor, domain)
 	}
-	return
+	return createResponse(value.Interface(), true, options, state)
 }
 
 // RespondWithInputErrors attempts to figure out where the input
@@ -278,11 +583,19 @@ func createResponseValue(value reflect.Value, options objx.Map, constructor func
 // ValidateInput method; if the field is a RequestValueReceiver, the
 // error value returned from Receive will be used to validate;
 // otherwise, we will attempt to check that the input value is
-// assignable to the field.
+// assignable to the field.  Fields that may be null (pointers,
+// Nullable values, "database/sql".Null* types and driver.Valuer
+// structs with the same structure) accept nil, and their other input
+// is checked against the underlying type.
 //
 // If checkMissing is true, required fields that have no value present in
 // the input parameters will be considered input errors and will be
 // added to the message map.
+//
+// All of the messages added here are translated by Respond, using
+// the message catalog (see AddMessages).  Errors returned by
+// InputValidator and RequestValueReceiver implementations will also
+// be translated if they are Translatable (e.g. a *LocalizedMessage).
 func RespondWithInputErrors(ctx context.Context, notifications MessageMap, data interface{}, checkMissing bool) error {
 	dataType := reflect.TypeOf(data)
 	if dataType.Kind() == reflect.Ptr {
@@ -299,7 +612,7 @@ func RespondWithInputErrors(ctx context.Context, notifications MessageMap, data
 	// input errors, so anything remaining in params has no matching
 	// field.
 	for key := range params {
-		notifications.SetInputMessage(key, "No target field found for this input")
+		notifications.SetInputMessage(key, Localize(MessageNoTargetField))
 	}
 	status := http.StatusBadRequest
 	if len(notifications.InputMessages()) == 0 {
@@ -307,6 +620,9 @@ func RespondWithInputErrors(ctx context.Context, notifications MessageMap, data
 		// went wrong - this is probably an internal server error.
 		status = http.StatusInternalServerError
 	}
+	// Respond only translates its own copy of the notifications, so
+	// translate the copy that is used as the response as well.
+	notifications = notifications.localized(responseLocale(ctx))
 	return Respond(ctx, status, notifications, notifications)
 }
 
@@ -333,25 +649,41 @@ func checkForInputError(fieldType reflect.Type, value interface{}) error {
 		return receiver.Receive(value)
 	}
 
-	fieldTypeName := fieldType.Name()
-	if fieldType.Kind() == reflect.Struct && strings.HasPrefix(fieldTypeName, SqlNullablePrefix) {
-		// database/sql defines many Null* types,
-		// where the fields are Valid (a bool) and the
-		// name of the type (everything after Null).
-		// We're trying to support them (somewhat)
-		// here.
-		typeName := fieldTypeName[len(SqlNullablePrefix):]
-		nullField, ok := fieldType.FieldByName(typeName)
-		if ok {
-			// This is almost definitely an sql.Null* type.
-			if value == nil {
+	baseType := fieldType
+	if baseType.Kind() == reflect.Ptr {
+		baseType = baseType.Elem()
+	}
+	if value == nil {
+		if fieldType.Kind() == reflect.Ptr || isNullableType(baseType) {
+			return nil
+		}
+		return Localize(MessageWrongType)
+	}
+	if baseType.Kind() == reflect.Struct {
+		if valueIndex, _, ok := nullableFields(baseType); ok {
+			// This is a "database/sql".Null* type (or something
+			// with the same structure), so the input should match
+			// the value field.
+			baseType = baseType.Field(valueIndex).Type
+		} else if isNullableType(baseType) {
+			scanner, canScan := emptyInter.(scanner)
+			if canScan {
+				if err := scanner.Scan(value); err != nil {
+					return Localize(MessageWrongType)
+				}
+				return nil
+			}
+			valueField, ok := nullShapeValue(baseType)
+			if !ok {
+				// A Nullable struct that can't Scan; there's
+				// nothing to check the input against.
 				return nil
 			}
-			fieldType = nullField.Type
+			baseType = valueField.Type
 		}
 	}
-	if !reflect.TypeOf(value).ConvertibleTo(fieldType) {
-		return errors.New("Input is of the wrong type and cannot be converted")
+	if !reflect.TypeOf(value).ConvertibleTo(baseType) {
+		return Localize(MessageWrongType)
 	}
 	return nil
 }
@@ -382,7 +714,7 @@ func addInputErrors(dataType reflect.Type, params objx.Map, notifications Messag
 			value, ok := params[name]
 			if !ok {
 				if !optional && checkMissing {
-					notifications.SetInputMessage(name, "No input for required field")
+					notifications.SetInputMessage(name, Localize(MessageMissingInput))
 				}
 				continue
 			}
@@ -393,7 +725,8 @@ func addInputErrors(dataType reflect.Type, params objx.Map, notifications Messag
 			delete(params, name)
 
 			if err := checkForInputError(field.Type, value); err != nil {
-				notifications.SetInputMessage(name, err.Error())
+				// Pass the error itself, in case it is Translatable.
+				notifications.SetInputMessage(name, err)
 			}
 		}
 	}
@@ -404,63 +737,230 @@ func addInputErrors(dataType reflect.Type, params objx.Map, notifications Messag
 // particular function is very specifically for use with the
 // github.com/stretchr/goweb web framework.
 //
+// The Location and Link headers are set for 200 OK, 201 Created and
+// 202 Accepted responses; see ResponseLocation and ResponseLinks.
+// Their links (and the domain passed to the codec, if useFullDomain
+// is true) are prefixed with the RequestDomain.
+//
+// A profile query parameter chooses the default Profile of the
+// response, unless the server has already set the "profile" codec
+// option; only ClientProfiles are accepted.
+//
+// The locale of the response is chosen from the Accept-Language
+// header (see NegotiateLocale); any Translatable notifications are
+// translated to it, and it is sent in the Content-Language header.
+//
+// Requests with a callback query parameter get a callback (JSONP)
+// response from codecs that support them, which is always sent as a
+// 200 OK; the real status is in the envelope's meta.  Callbacks are
+// only allowed for unauthenticated GET requests (i.e. without an
+// Authorization header or a "principal" codec option), since any page
+// can load them; other callback requests, and callbacks that aren't
+// ValidCallback names, result in a 400 Bad Request.
+//
+// Codecs that implement StreamingCodec write the response to the
+// client as it is created, instead of creating the whole response
+// first.
+//
+// Clients that ask for status suppression (see StatusSuppressed) also
+// get every response as a 200 OK, and the codec is told about it with
+// the StatusSuppressedOption.
+//
 // TODO: Move the with={} parameter to options in the mimetypes in the
 // Accept header.
 func Respond(ctx context.Context, status int, notifications MessageMap, data interface{}, useFullDomain ...bool) error {
-	body, err := web_request_readers.ParseBody(ctx)
+	requestDomain := RequestDomain(ctx.HttpRequest())
+
+	// Transitionary period - don't pass the domain to the codec
+	// unless it's requested in the responder
+	codecDomain := ""
+	if len(useFullDomain) > 0 && useFullDomain[0] {
+		codecDomain = requestDomain
+	}
+	options, err := setCodecOptions(ctx, status, notifications, codecDomain)
+	if localized, ok := options["notifications"].(MessageMap); ok {
+		notifications = localized
+	}
+	if optionErr, ok := err.(*OptionError); ok {
+		return respondWithResponseError(ctx, notifications, optionErr)
+	}
 	if err != nil {
 		return err
 	}
-	if ctx.QueryParams().Has("joins") {
-		if m, ok := body.(objx.Map); ok {
-			m.Set("joins", ctx.QueryValue("joins"))
-		}
+
+	header := ctx.HttpResponseWriter().Header()
+	if location := ResponseLocation(status, data); location != "" {
+		header.Set("Location", Link{Href: location}.Absolute(requestDomain).Href)
+	}
+	if links := ResponseLinks(status, data).Absolute(requestDomain); len(links) > 0 {
+		header.Set("Link", links.HeaderValue())
 	}
 
-	protocol := "http"
-	if ctx.HttpRequest().TLS != nil {
-		protocol += "s"
+	if callback := ctx.QueryValue(callbackParameter); callback != "" {
+		codec, err := respondingCodec(ctx)
+		if callbackCodec, ok := codec.(callbackCodec); err == nil && ok && callbackCodec.CanMarshalWithCallback() {
+			return respondWithCallback(ctx, callbackCodec, callback, status, notifications, data)
+		}
 	}
 
-	host := ctx.HttpRequest().Host
+	if writesMetaHeaders(ctx) {
+		setMetaHeaders(ctx, status, notifications)
+	}
 
-	requestDomain := fmt.Sprintf("%s://%s", protocol, host)
-	if status == http.StatusOK {
-		location := "Error: no location present"
-		if locationer, ok := data.(Locationer); ok {
-			location = fmt.Sprintf("%s%s", requestDomain, locationer.Location())
+	if codec, err := respondingCodec(ctx); err == nil {
+		if streamingCodec, ok := codec.(StreamingCodec); ok {
+			return respondWithStream(ctx, streamingCodec, status, notifications, data)
 		}
-		ctx.HttpResponseWriter().Header().Set("Location", location)
+	}
 
-		if linker, ok := data.(RelatedLinker); ok {
-			linkMap := linker.RelatedLinks()
-			links := make([]string, 0, len(linkMap)+1)
-			links = append(links, fmt.Sprintf(`<%s>; rel="location"`, location))
-			for rel, link := range linkMap {
-				link := fmt.Sprintf(`<%s%s>; rel="%s"`, requestDomain, link, rel)
-				links = append(links, link)
+	// Right now, this line is commented out to support our joins
+	// logic.  Unfortunately, that means that codecs other than our
+	// custom codecs from this package will not work.  Whoops.
+	// data = CreateResponse(data)
+
+	err = goweb.API.WriteResponseObject(ctx, writtenStatus(ctx, status), data)
+	if responseErr, ok := err.(ResponseError); ok {
+		return respondWithResponseError(ctx, notifications, responseErr)
+	}
+	return err
+}
+
+// setCodecOptions adds the values that our codecs need (the status,
+// input params, notifications, domain and locale, along with any
+// options from the query parameters) to the context's CodecOptions,
+// and returns them.  The notifications option is a copy of
+// notifications, translated to the locale of the response, so the
+// caller's MessageMap is never changed.  An *OptionError is returned if the query
+// parameters contain options that aren't allowed; the codec options
+// are still set, so that an error response can be written.
+func setCodecOptions(ctx context.Context, status int, notifications MessageMap, domain string) (objx.Map, error) {
+	body, err := web_request_readers.ParseBody(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := body.(objx.Map); ok {
+		for _, param := range []string{"joins", "fields"} {
+			if ctx.QueryParams().Has(param) {
+				m.Set(param, ctx.QueryValue(param))
 			}
-			ctx.HttpResponseWriter().Header().Set("Link", strings.Join(links, ", "))
 		}
 	}
-	// Transitionary period - don't pass the domain to the codec
-	// unless it's requested in the responder
-	if len(useFullDomain) == 0 || useFullDomain[0] == false {
-		requestDomain = ""
-	}
+
+	// Translate notifications to the best locale for the client.
+	locale := responseLocale(ctx)
+	notifications = notifications.localized(locale)
+	ctx.HttpResponseWriter().Header().Set("Content-Language", locale)
 
 	options := ctx.CodecOptions()
 	options.MergeHere(objx.Map{
 		"status":        status,
 		"input_params":  body,
 		"notifications": notifications,
-		"domain":        requestDomain,
+		"domain":        domain,
+		"locale":        locale,
 	})
+	addVary(ctx.HttpResponseWriter().Header(), SuppressStatusHeader)
+	if StatusSuppressed(ctx) {
+		options.Set(StatusSuppressedOption, true)
+	}
+	for _, param := range []string{"time_format", "timezone", "duration_format"} {
+		if ctx.QueryParams().Has(param) {
+			options.Set(param, ctx.QueryValue(param))
+		}
+	}
 
-	// Right now, this line is commented out to support our joins
-	// logic.  Unfortunately, that means that codecs other than our
-	// custom codecs from this package will not work.  Whoops.
-	// data = CreateResponse(data)
+	// The server may have already chosen a profile (e.g. ProfileAdmin
+	// for administrators), which the client can't override.
+	if ctx.QueryParams().Has("profile") {
+		profile := Profile(ctx.QueryValue("profile"))
+		if !ClientProfiles[profile] {
+			return options, &OptionError{Message: "Unknown profile: " + string(profile)}
+		}
+		if !options.Has("profile") {
+			options.Set("profile", profile)
+		}
+	}
+	return options, nil
+}
+
+// responseLocale returns the locale of the response for ctx, chosen
+// from the Accept-Language header.
+func responseLocale(ctx context.Context) string {
+	return NegotiateLocale(ctx.HttpRequest().Header.Get("Accept-Language"))
+}
+
+// RespondCreated performs a 201 Created response for data, which
+// should be a Locationer so that the Location header can point to
+// the new resource.
+func RespondCreated(ctx context.Context, notifications MessageMap, data interface{}, useFullDomain ...bool) error {
+	return Respond(ctx, http.StatusCreated, notifications, data, useFullDomain...)
+}
+
+// RespondAccepted performs a 202 Accepted response for data, for
+// requests that will be processed later.  If data is a
+// StatusLocationer, the Location header will point to its status
+// monitor.
+func RespondAccepted(ctx context.Context, notifications MessageMap, data interface{}, useFullDomain ...bool) error {
+	return Respond(ctx, http.StatusAccepted, notifications, data, useFullDomain...)
+}
+
+// respondWithResponseError replaces a response that our codecs could
+// not create (e.g. because the client sent malformed joins) with an
+// error response.  The codecs create the whole response before
+// anything is written, so nothing has been sent to the client yet.
+func respondWithResponseError(ctx context.Context, notifications MessageMap, responseErr ResponseError) error {
+	if notifications == nil {
+		notifications = NewMessageMap()
+	}
+	notifications.AddErrorMessage(responseErr.Error())
+	status := responseErr.ResponseStatus()
+
+	header := ctx.HttpResponseWriter().Header()
+	header.Del("Location")
+	header.Del("Link")
+
+	// The joins are the most likely cause of the error, so make sure
+	// they aren't used again.
+	options := ctx.CodecOptions()
+	options.MergeHere(objx.Map{
+		"status":        status,
+		"notifications": notifications,
+		"joins":         "",
+	})
+	if writesMetaHeaders(ctx) {
+		setMetaHeaders(ctx, status, notifications)
+	}
+	return goweb.API.WriteResponseObject(ctx, writtenStatus(ctx, status), nil)
+}
+
+// respondingCodec looks up the codec that goweb will use to write the
+// response for ctx.
+func respondingCodec(ctx context.Context) (interface{}, error) {
+	accept := ctx.HttpRequest().Header.Get("Accept")
+	hasCallback := ctx.QueryValue(callbackParameter) != ""
+	return goweb.CodecService.GetCodecForResponding(accept, ctx.FileExtension(), hasCallback)
+}
 
-	return goweb.API.WriteResponseObject(ctx, status, data)
+// writesMetaHeaders returns whether or not the codec for ctx is a
+// MetaHeaderCodec that needs meta values sent as headers.
+func writesMetaHeaders(ctx context.Context) bool {
+	codec, err := respondingCodec(ctx)
+	if err != nil {
+		return false
+	}
+	metaCodec, ok := codec.(MetaHeaderCodec)
+	return ok && metaCodec.WritesMetaHeaders()
+}
+
+// setMetaHeaders sends the values that would normally be in the
+// envelope's meta as response headers, for codecs that can't write
+// the envelope.
+func setMetaHeaders(ctx context.Context, status int, notifications MessageMap) {
+	header := ctx.HttpResponseWriter().Header()
+	header.Set(codeHeader, strconv.Itoa(status))
+	if notifications != nil {
+		if encoded, err := json.Marshal(notifications); err == nil {
+			header.Set(notificationsHeader, string(encoded))
+		}
+	}
 }
@@ -0,0 +1,118 @@
+package web_responders
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/stretchr/objx"
+	"testing"
+)
+
+// The types below are meant to look like the models that usually end
+// up in our list endpoints: embedded base fields, database/sql
+// nullable values, nested pointers, and sub-values that create their
+// own response values.
+
+type BenchBase struct {
+	Id      int64 `db:"id"`
+	Created int64 `db:"created_at"`
+	Updated int64 `db:"updated_at"`
+}
+
+type benchGenre string
+
+func (genre benchGenre) String() string {
+	return string(genre)
+}
+
+type benchArtist struct {
+	BenchBase
+	Name    string         `db:"name"`
+	Country sql.NullString `db:"country"`
+}
+
+func (artist *benchArtist) ResponseValue(options objx.Map) interface{} {
+	return fmt.Sprintf("/artists/%d", artist.Id)
+}
+
+type benchSong struct {
+	BenchBase
+	Title      string         `db:"title"`
+	Duration   int            `db:"duration"`
+	Explicit   bool           `db:"explicit"`
+	Isrc       sql.NullString `db:"isrc"`
+	PlayCount  sql.NullInt64  `db:"play_count"`
+	Rating     sql.NullFloat64
+	Genre      benchGenre
+	Artist     *benchArtist
+	Featuring  []*benchArtist
+	Tags       []string
+	Properties map[string]string
+	internal   string
+	Skipped    string `response:"-"`
+}
+
+func benchSongs(count int) []*benchSong {
+	songs := make([]*benchSong, 0, count)
+	for i := 0; i < count; i++ {
+		artist := &benchArtist{
+			BenchBase: BenchBase{Id: int64(i)},
+			Name:      "Artist",
+			Country:   sql.NullString{String: "CA", Valid: true},
+		}
+		songs = append(songs, &benchSong{
+			BenchBase:  BenchBase{Id: int64(i), Created: 1, Updated: 2},
+			Title:      "Song",
+			Duration:   215,
+			Isrc:       sql.NullString{String: "CA-XXX-13-00001", Valid: true},
+			PlayCount:  sql.NullInt64{Int64: 12, Valid: true},
+			Genre:      "electronic",
+			Artist:     artist,
+			Featuring:  []*benchArtist{artist},
+			Tags:       []string{"chill", "night"},
+			Properties: map[string]string{"bpm": "120"},
+		})
+	}
+	return songs
+}
+
+func BenchmarkCreateResponseStruct(b *testing.B) {
+	song := benchSongs(1)[0]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CreateResponse(song)
+	}
+}
+
+func BenchmarkCreateResponseList(b *testing.B) {
+	songs := benchSongs(100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CreateResponse(songs)
+	}
+}
+
+func BenchmarkCreateResponseListParallel(b *testing.B) {
+	songs := benchSongs(100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			CreateResponse(songs)
+		}
+	})
+}
+
+// BenchmarkCreateResponseListUncached clears the struct plan cache
+// before every call, to show the cost of parsing each type's fields
+// on every response.
+func BenchmarkCreateResponseListUncached(b *testing.B) {
+	songs := benchSongs(100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resetStructPlans()
+		CreateResponse(songs)
+	}
+}
@@ -0,0 +1,290 @@
+package web_responders
+
+import (
+	"database/sql"
+	"github.com/stretchr/objx"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testPlaceholder struct{}
+
+func (placeholder *testPlaceholder) NilResponseValue() interface{} {
+	return "none"
+}
+
+type testSilentPlaceholder struct{}
+
+func (placeholder *testSilentPlaceholder) NilResponseValue() interface{} {
+	return nil
+}
+
+func TestCreateResponseOmitOptions(t *testing.T) {
+	type omitting struct {
+		Title       string                 `response:"title,omitempty"`
+		Plays       int                    `response:"plays,omitempty"`
+		Released    time.Time              `response:"released,omitempty"`
+		Isrc        sql.NullString         `response:"isrc,omitempty"`
+		BlankIsrc   sql.NullString         `response:"blank_isrc,omitempty"`
+		Country     sql.NullString         `response:"country,omitnil"`
+		Placeholder *testPlaceholder       `response:"placeholder,omitnil"`
+		Silent      *testSilentPlaceholder `response:"silent,omitnil"`
+		Kept        string                 `response:"kept"`
+	}
+	value := omitting{BlankIsrc: sql.NullString{Valid: true}}
+
+	response := CreateResponse(value)
+	assert.Equal(t, objx.Map{
+		"placeholder": "none",
+		"kept":        "",
+	}, response)
+}
+
+type testPointerZeroer struct {
+	Value string
+}
+
+func (zeroer *testPointerZeroer) IsZero() bool {
+	return zeroer.Value == "" || zeroer.Value == "zero"
+}
+
+func TestCreateResponseOmitEmptyPointerIsZeroer(t *testing.T) {
+	type holder struct {
+		Zeroer testPointerZeroer `response:"zeroer,omitempty"`
+	}
+	value := holder{Zeroer: testPointerZeroer{Value: "zero"}}
+	assert.Equal(t, objx.Map{}, CreateResponse(value))
+	assert.Equal(t, objx.Map{}, CreateResponse(&value))
+	assert.Equal(t, map[string]interface{}{"a": objx.Map{}}, CreateResponse(map[string]holder{"a": value}))
+}
+
+type testKey int
+
+func (key testKey) String() string {
+	return "key-" + strconv.Itoa(int(key))
+}
+
+type testName string
+
+type testPoint struct {
+	X int
+}
+
+func (point testPoint) String() string {
+	return "point-" + strconv.Itoa(point.X)
+}
+
+func TestCreateResponseMapKeyCollisions(t *testing.T) {
+	_, err := CreateResponseWithOptions(map[interface{}]int{1: 1, "1": 2})
+	if assert.IsType(t, &MapKeyError{}, err) {
+		assert.Equal(t, "1", err.(*MapKeyError).Key)
+	}
+
+	_, err = CreateResponseWithOptions(map[testPoint]int{{1}: 1, {2}: 2})
+	assert.NoError(t, err)
+}
+
+func TestCreateResponseMapKeys(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{"1": 2, "3": 4}, CreateResponse(map[int]int{1: 2, 3: 4}))
+	assert.Equal(t, map[string]interface{}{"1": "one"}, CreateResponse(map[testKey]string{1: "one"}))
+	assert.Equal(t, map[string]interface{}{"point-1": "one"}, CreateResponse(map[testPoint]string{{1}: "one"}))
+	assert.Equal(t, map[string]interface{}{"name": 1.5}, CreateResponse(map[testName]float64{"name": 1.5}))
+	assert.Equal(t, map[string]interface{}{"true": []interface{}{"a"}}, CreateResponse(map[bool][]string{true: {"a"}}))
+}
+
+type testNode struct {
+	Name   string
+	Parent *testNode
+}
+
+func TestCreateResponseWithOptionsErrors(t *testing.T) {
+	type withChild struct {
+		Child testNode
+	}
+	_, err := CreateResponseWithOptions(withChild{}, WithJoins(objx.Map{"child": "everything"}))
+	if assert.IsType(t, &OptionError{}, err) {
+		assert.Equal(t, "child", err.(*OptionError).Path)
+	}
+
+	type withCallback struct {
+		Callback func()
+	}
+	_, err = CreateResponseWithOptions(withCallback{Callback: func() {}})
+	assert.IsType(t, &UnsupportedKindError{}, err)
+
+	_, err = positionalOptions([]interface{}{"http://example.com", 42})
+	assert.IsType(t, &OptionError{}, err)
+	assert.Panics(t, func() { CreateResponse("value", 42) })
+
+	node := &testNode{Name: "loop"}
+	node.Parent = node
+	_, err = CreateResponseWithOptions(node)
+	if assert.IsType(t, &CycleError{}, err) {
+		assert.Equal(t, "parent", err.(*CycleError).Path)
+	}
+}
+
+type testLocatedNode struct {
+	Name     string
+	Children []*testLocatedNode
+	Parent   *testLocatedNode
+}
+
+func (node *testLocatedNode) Location() string {
+	return "/nodes/" + node.Name
+}
+
+func TestCreateResponseCyclesAndDepth(t *testing.T) {
+	parent := &testLocatedNode{Name: "parent"}
+	parent.Children = []*testLocatedNode{{Name: "child", Parent: parent}}
+
+	response, err := CreateResponseWithOptions(parent, WithDomain("http://example.com"))
+	if assert.NoError(t, err) {
+		child := response.(objx.Map)["children"].([]interface{})[0].(objx.Map)
+		assert.Equal(t, "http://example.com/nodes/parent", child["parent"])
+	}
+
+	_, err = CreateResponseWithOptions(parent, WithCyclePolicy(CyclesAsErrors))
+	assert.IsType(t, &CycleError{}, err)
+
+	_, err = CreateResponseWithOptions(parent, WithMaxDepth(2))
+	if assert.IsType(t, &DepthError{}, err) {
+		assert.Equal(t, "children.0.children", err.(*DepthError).Path)
+	}
+}
+
+func TestCreateResponseDepthErrorStatus(t *testing.T) {
+	parent := &testLocatedNode{Name: "parent"}
+	parent.Children = []*testLocatedNode{{Name: "child"}}
+	parent.Children[0].Children = []*testLocatedNode{{Name: "grandchild"}}
+
+	_, err := CreateResponseWithOptions(parent, WithMaxDepth(2))
+	if assert.IsType(t, &DepthError{}, err) {
+		assert.Equal(t, http.StatusInternalServerError, err.(*DepthError).ResponseStatus())
+	}
+
+	joins := objx.Map{"children": objx.Map{"children": objx.Map{}}}
+	_, err = CreateResponseWithOptions(parent, WithMaxDepth(2), WithJoins(joins))
+	if assert.IsType(t, &DepthError{}, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*DepthError).ResponseStatus())
+	}
+}
+
+func TestCreateResponseSliceAndMapCycles(t *testing.T) {
+	slice := []interface{}{"a", nil}
+	slice[1] = slice
+	_, err := CreateResponseWithOptions(slice, WithMaxDepth(0))
+	assert.IsType(t, &CycleError{}, err)
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	_, err = CreateResponseWithOptions(m, WithMaxDepth(0))
+	assert.IsType(t, &CycleError{}, err)
+
+	shared := []string{"a"}
+	response, err := CreateResponseWithOptions([][]string{shared, shared}, WithMaxDepth(0))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{"a"}, []interface{}{"a"}}, response)
+}
+
+func TestCreateResponseWithOptionsFields(t *testing.T) {
+	type artist struct {
+		Name    string
+		Country string
+	}
+	type song struct {
+		Title  string
+		Plays  int
+		Artist artist
+	}
+	songs := []song{{"Intro", 10, artist{"The xx", "UK"}}}
+
+	response, err := CreateResponseWithOptions(songs, WithFields("title", "artist.name"))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{objx.Map{
+		"title":  "Intro",
+		"artist": objx.Map{"name": "The xx"},
+	}}, response)
+}
+
+func TestCreateResponseTimes(t *testing.T) {
+	type event struct {
+		Start    time.Time
+		End      *time.Time
+		Ended    sql.NullTime
+		Length   time.Duration
+		Optional *time.Time
+	}
+	vancouver := time.FixedZone("PST", -8*60*60)
+	start := time.Date(2013, 11, 5, 16, 30, 0, 0, vancouver)
+	end := start.Add(90 * time.Minute)
+	value := event{start, &end, sql.NullTime{Time: end, Valid: true}, end.Sub(start), nil}
+
+	response, err := CreateResponseWithOptions(value)
+	assert.NoError(t, err)
+	assert.Equal(t, objx.Map{
+		"start":    "2013-11-06T00:30:00Z",
+		"end":      "2013-11-06T02:00:00Z",
+		"ended":    "2013-11-06T02:00:00Z",
+		"length":   "1h30m0s",
+		"optional": nil,
+	}, response)
+
+	response, err = CreateResponseWithOptions(value, WithTimeFormat(UnixMillis), WithDurationFormat(DurationSeconds))
+	assert.NoError(t, err)
+	assert.Equal(t, start.UnixNano()/int64(time.Millisecond), response.(objx.Map)["start"])
+	assert.Equal(t, 5400.0, response.(objx.Map)["length"])
+}
+
+type testLinkedPost struct {
+	Comment string
+	Self    URL
+	Artwork string   `response:"artwork,link"`
+	Mirrors []string `response:"mirrors,link"`
+	Next    *URL
+	Edit    Link
+}
+
+func TestCreateResponseLinks(t *testing.T) {
+	next := URL("/posts/2")
+	post := testLinkedPost{
+		Comment: "/r/music is great",
+		Self:    "/posts/1",
+		Artwork: "/images/1.png",
+		Mirrors: []string{"/mirror/1", "http://other.com/1"},
+		Next:    &next,
+		Edit:    Link{Href: "/posts/1", Method: "PUT"},
+	}
+	response, err := CreateResponseWithOptions(post, WithDomain("https://radiobox.com"))
+	assert.NoError(t, err)
+	m := response.(objx.Map)
+	assert.Equal(t, "/r/music is great", m["comment"])
+	assert.Equal(t, "https://radiobox.com/posts/1", m["self"])
+	assert.Equal(t, "https://radiobox.com/images/1.png", m["artwork"])
+	assert.Equal(t, []interface{}{"https://radiobox.com/mirror/1", "http://other.com/1"}, m["mirrors"])
+	assert.Equal(t, "https://radiobox.com/posts/2", m["next"])
+	assert.Equal(t, map[string]interface{}{"href": "https://radiobox.com/posts/1", "method": "PUT"}, m["edit"])
+}
+
+type testVersionedCode string
+
+func (code testVersionedCode) ResponseValueWithOptions(joins objx.Map, options *ResponseOptions) interface{} {
+	if options.Version == "" {
+		return string(code)
+	}
+	return "opts-" + options.Version
+}
+
+func TestCreateResponseWithOptionsScalarField(t *testing.T) {
+	type song struct {
+		Title string
+		Code  testVersionedCode
+	}
+
+	response, err := CreateResponseWithOptions(song{"Intro", "x"}, WithVersion("v2"))
+	assert.NoError(t, err)
+	assert.Equal(t, objx.Map{"title": "Intro", "code": "opts-v2"}, response)
+}
@@ -0,0 +1,140 @@
+package web_responders
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// A ResponseError is an error that happened while creating a response
+// value with CreateResponseWithOptions.  ResponseStatus returns the
+// HTTP status code that should be used when responding with the
+// error; Respond uses it to replace the original response with an
+// error response.
+type ResponseError interface {
+	error
+	ResponseStatus() int
+}
+
+// describePath returns a description of a path within a response,
+// for error messages.
+func describePath(path string) string {
+	if path == "" {
+		return "the response"
+	}
+	return `"` + path + `"`
+}
+
+// An OptionError means that the options passed to
+// CreateResponseWithOptions were malformed.  Since the joins options
+// usually come from the client, this results in a 400 Bad Request.
+type OptionError struct {
+	// Path is the dot separated path to the value that the options
+	// were meant for.
+	Path string
+
+	Message string
+}
+
+func (err *OptionError) Error() string {
+	return fmt.Sprintf("Invalid options for %s: %s", describePath(err.Path), err.Message)
+}
+
+func (err *OptionError) ResponseStatus() int {
+	return http.StatusBadRequest
+}
+
+// An UnsupportedKindError means that a value could not be converted
+// to a response value, because its kind (e.g. chan or func) has no
+// meaningful representation in a response.
+type UnsupportedKindError struct {
+	Path string
+	Type reflect.Type
+}
+
+func (err *UnsupportedKindError) Error() string {
+	return fmt.Sprintf("Cannot create a response value for %s of type %s", describePath(err.Path), err.Type)
+}
+
+func (err *UnsupportedKindError) ResponseStatus() int {
+	return http.StatusInternalServerError
+}
+
+// A MapKeyError means that the keys of a map could not be converted
+// to distinct strings for its response value (see mapKeyString).
+// Err is the error from the key's MarshalText method, or nil if two
+// keys converted to the same string.
+type MapKeyError struct {
+	Path string
+	Key  string
+	Err  error
+}
+
+func (err *MapKeyError) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("Cannot create a response value for %s: could not convert a map key to a string: %s", describePath(err.Path), err.Err)
+	}
+	return fmt.Sprintf("Cannot create a response value for %s: more than one map key converts to %q", describePath(err.Path), err.Key)
+}
+
+func (err *MapKeyError) ResponseStatus() int {
+	return http.StatusInternalServerError
+}
+
+// A CycleError means that a value contains a reference to itself
+// (directly or through its sub-values), so creating its response
+// value would never finish.
+type CycleError struct {
+	Path string
+	Type reflect.Type
+}
+
+func (err *CycleError) Error() string {
+	return fmt.Sprintf("Cannot create a response value for %s: the value of type %s refers back to itself", describePath(err.Path), err.Type)
+}
+
+func (err *CycleError) ResponseStatus() int {
+	return http.StatusInternalServerError
+}
+
+// A DepthError means that a response value was nested more deeply
+// than the MaxDepth allows.  If the value was included because of the
+// client's joins, this results in a 400 Bad Request; otherwise, the
+// server's own data is too deep, which is a 500 Internal Server
+// Error.
+type DepthError struct {
+	Path     string
+	MaxDepth MaxDepth
+
+	// Joined is true if the value at Path was included because of
+	// the joins options.
+	Joined bool
+}
+
+func (err *DepthError) Error() string {
+	return fmt.Sprintf("Cannot create a response value for %s: responses may only be nested %d levels deep", describePath(err.Path), err.MaxDepth)
+}
+
+func (err *DepthError) ResponseStatus() int {
+	if err.Joined {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// A RendererError means that a field's "render" tag option names a
+// Renderer that has not been registered (see RegisterRenderer).  This
+// is a mistake in the code rather than in the request, so it results
+// in a 500 Internal Server Error.
+type RendererError struct {
+	Path string
+	Name string
+}
+
+func (err *RendererError) Error() string {
+	return fmt.Sprintf("Cannot create a response value for %s: no renderer named %q", describePath(err.Path), err.Name)
+}
+
+func (err *RendererError) ResponseStatus() int {
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,53 @@
+package web_responders
+
+import (
+	"reflect"
+)
+
+// MaxDepth limits how deeply nested a response value may be (see
+// ResponseOptions.MaxDepth).  Values nested more deeply than the
+// maximum result in a *DepthError, which stops clients from using
+// deep joins to make the server do an unbounded amount of work.  A
+// MaxDepth of zero or less means that there is no limit.
+type MaxDepth int
+
+// DefaultMaxDepth is the MaxDepth set by NewResponseOptions.
+var DefaultMaxDepth MaxDepth = 32
+
+// CyclePolicy controls what CreateResponseWithOptions does when a
+// value refers back to one of the values that contain it (e.g. a
+// child with a pointer to its parent).  The default is
+// CyclesAsReferences.
+type CyclePolicy int
+
+const (
+	// CyclesAsReferences renders the repeated value as a reference:
+	// its location, if it is a Locationer, or otherwise the value of
+	// its "id" field.  If neither is available, a *CycleError is
+	// returned.
+	CyclesAsReferences CyclePolicy = iota
+
+	// CyclesAsErrors always returns a *CycleError.
+	CyclesAsErrors
+)
+
+// reference returns the value used in place of data when data is
+// repeated within itself.  See CyclesAsReferences.
+func (state *responseState) reference(data interface{}, value reflect.Value) (interface{}, bool) {
+	if locationer, ok := data.(Locationer); ok {
+		return URL(locationer.Location()).Absolute(state.options.Domain), true
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, false
+	}
+	plan, err := structPlanFor(value.Type())
+	if err != nil {
+		return nil, false
+	}
+	for _, field := range plan.fields {
+		if field.name == "id" && !field.embedded {
+			return value.FieldByIndex(field.index).Interface(), true
+		}
+	}
+	return nil, false
+}
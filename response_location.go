@@ -0,0 +1,43 @@
+package web_responders
+
+import (
+	"net/http"
+)
+
+// ResponseLocation returns the location for a response with status and
+// data, relative to the server root path, or an empty string if there
+// is none.  200 OK and 201 Created responses use the location of a
+// Locationer; 202 Accepted responses use the status monitor location
+// of a StatusLocationer, falling back to the location of a
+// Locationer.  Responses with any other status have no location.
+func ResponseLocation(status int, data interface{}) string {
+	switch status {
+	case http.StatusAccepted:
+		if locationer, ok := data.(StatusLocationer); ok {
+			return locationer.StatusLocation()
+		}
+		fallthrough
+	case http.StatusOK, http.StatusCreated:
+		if locationer, ok := data.(Locationer); ok {
+			return locationer.Location()
+		}
+	}
+	return ""
+}
+
+// ResponseLinks returns the links for a response with status and data,
+// including a "location" link for the ResponseLocation, if there is
+// one.  Only responses that can have a location (see ResponseLocation)
+// have links.
+func ResponseLinks(status int, data interface{}) Links {
+	switch status {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+	default:
+		return Links{}
+	}
+	links := LinksFor(data)
+	if location := ResponseLocation(status, data); location != "" {
+		links["location"] = []Link{{Href: location}}
+	}
+	return links
+}
@@ -0,0 +1,35 @@
+package web_responders
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+type testJob struct{}
+
+func (job testJob) Location() string {
+	return "/results/1"
+}
+
+func (job testJob) StatusLocation() string {
+	return "/jobs/1"
+}
+
+func TestResponseLocation(t *testing.T) {
+	node := &testLocatedNode{Name: "1"}
+	assert.Equal(t, "/nodes/1", ResponseLocation(http.StatusOK, node))
+	assert.Equal(t, "/nodes/1", ResponseLocation(http.StatusCreated, node))
+	assert.Equal(t, "/nodes/1", ResponseLocation(http.StatusAccepted, node))
+	assert.Equal(t, "/jobs/1", ResponseLocation(http.StatusAccepted, testJob{}))
+	assert.Equal(t, "/results/1", ResponseLocation(http.StatusCreated, testJob{}))
+	assert.Equal(t, "", ResponseLocation(http.StatusNoContent, node))
+	assert.Equal(t, "", ResponseLocation(http.StatusOK, struct{}{}))
+}
+
+func TestResponseLinks(t *testing.T) {
+	links := ResponseLinks(http.StatusCreated, &testLocatedNode{Name: "1"})
+	assert.Equal(t, Links{"location": {{Href: "/nodes/1"}}}, links)
+	assert.Empty(t, ResponseLinks(http.StatusOK, struct{}{}))
+	assert.Empty(t, ResponseLinks(http.StatusBadRequest, &testLocatedNode{Name: "1"}))
+}
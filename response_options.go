@@ -0,0 +1,313 @@
+package web_responders
+
+import (
+	"github.com/stretchr/objx"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResponseOptions holds all of the options used when creating a
+// response value.  Use NewResponseOptions or CreateResponseWithOptions
+// to get ResponseOptions with the default values filled in.
+type ResponseOptions struct {
+	// Joins is the tree of joins options, usually from the client's
+	// joins parameter.  Each level of the tree is passed to the
+	// LazyLoad and ResponseValue methods of the matching sub-values.
+	Joins objx.Map
+
+	// Constructor is used to wrap collections that are embedded in a
+	// response because of joins, usually in the same envelope used
+	// for the whole response.
+	Constructor func(interface{}, interface{}) interface{}
+
+	// ResourceFormatter, when it is not nil, is called with each
+	// struct value nested in the response (including the elements of
+	// a collection) and the object created for it.  The value it
+	// returns is used in place of the object.  Codecs use this to
+	// format resources for hypermedia formats, e.g. adding HAL's
+	// _links.
+	ResourceFormatter func(original interface{}, object map[string]interface{}) interface{}
+
+	// Domain is prepended to relative links (see URL).
+	Domain string
+
+	// Fields, when it is not empty, limits the fields that will be
+	// included in objects.  Nested fields are separated with a dot,
+	// e.g. []string{"title", "artist.name"}; naming an object (e.g.
+	// "artist") includes all of its fields.
+	Fields []string
+
+	// MaxDepth limits how deeply nested the response may be.
+	MaxDepth MaxDepth
+
+	// Cycles controls what happens when a value refers back to
+	// itself.
+	Cycles CyclePolicy
+
+	// TimeFormat converts time.Time values (including *time.Time
+	// and nullable times like sql.NullTime) to response values, after
+	// converting them to TimeLocation (unless it is nil).
+	TimeFormat   TimeFormat
+	TimeLocation *time.Location
+
+	// DurationFormat converts time.Duration values to response
+	// values.
+	DurationFormat DurationFormat
+
+	// Profile is the level of detail for values that don't have a
+	// "profile" in their joins options.  See Profile.
+	Profile Profile
+
+	// Locale is the locale that the response is being created for,
+	// e.g. "en-US".
+	Locale string
+
+	// Principal is the authenticated user (or other entity) that the
+	// response is being created for, if any.
+	Principal interface{}
+
+	// Version is the API version that the response is being created
+	// for, if any.
+	Version string
+}
+
+// A ResponseOption sets a value on a ResponseOptions.
+type ResponseOption func(*ResponseOptions)
+
+// NewResponseOptions returns ResponseOptions with the default values,
+// after applying all of opts.
+func NewResponseOptions(opts ...ResponseOption) *ResponseOptions {
+	options := &ResponseOptions{
+		MaxDepth:       DefaultMaxDepth,
+		Profile:        ProfileDefault,
+		TimeFormat:     DefaultTimeFormat,
+		TimeLocation:   DefaultTimeLocation,
+		DurationFormat: DefaultDurationFormat,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithJoins sets the joins options.
+func WithJoins(joins objx.Map) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Joins = joins
+	}
+}
+
+// WithConstructor sets the constructor used for embedded
+// collections.
+func WithConstructor(constructor func(interface{}, interface{}) interface{}) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Constructor = constructor
+	}
+}
+
+// WithResourceFormatter sets the formatter used for nested
+// resources.
+func WithResourceFormatter(formatter func(interface{}, map[string]interface{}) interface{}) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.ResourceFormatter = formatter
+	}
+}
+
+// WithDomain sets the domain that is prepended to links.
+func WithDomain(domain string) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Domain = domain
+	}
+}
+
+// WithFields limits the fields included in objects.  See
+// ResponseOptions.Fields.
+func WithFields(fields ...string) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Fields = fields
+	}
+}
+
+// WithMaxDepth sets the maximum depth of the response.
+func WithMaxDepth(maxDepth MaxDepth) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.MaxDepth = maxDepth
+	}
+}
+
+// WithCyclePolicy sets the CyclePolicy for the response.
+func WithCyclePolicy(cycles CyclePolicy) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Cycles = cycles
+	}
+}
+
+// WithTimeFormat sets the TimeFormat used for times.
+func WithTimeFormat(format TimeFormat) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.TimeFormat = format
+	}
+}
+
+// WithTimeLocation sets the location that times are converted to
+// before they are formatted.
+func WithTimeLocation(location *time.Location) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.TimeLocation = location
+	}
+}
+
+// WithDurationFormat sets the DurationFormat used for durations.
+func WithDurationFormat(format DurationFormat) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.DurationFormat = format
+	}
+}
+
+// WithProfile sets the default Profile of the response.
+func WithProfile(profile Profile) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Profile = profile
+	}
+}
+
+// WithLocale sets the locale of the response.
+func WithLocale(locale string) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Locale = locale
+	}
+}
+
+// WithPrincipal sets the principal that the response is for.
+func WithPrincipal(principal interface{}) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Principal = principal
+	}
+}
+
+// WithVersion sets the API version of the response.
+func WithVersion(version string) ResponseOption {
+	return func(options *ResponseOptions) {
+		options.Version = version
+	}
+}
+
+// CreateResponseWithOptions creates a response value for data, using
+// the default ResponseOptions after applying all of opts.  See
+// CreateResponse for details about how response values are created.
+//
+// Any error returned will be a ResponseError - an *OptionError if the
+// options are malformed, an *UnsupportedKindError if some part of
+// data can't be represented in a response (e.g. a chan or func), a
+// *CycleError if data refers back to itself and can't be rendered as
+// a reference (see CyclePolicy), or a *DepthError if data is nested
+// too deeply (see MaxDepth).
+func CreateResponseWithOptions(data interface{}, opts ...ResponseOption) (interface{}, error) {
+	return NewResponseOptions(opts...).CreateResponse(data)
+}
+
+// CreateResponse creates a response value for data using these
+// options.
+func (options *ResponseOptions) CreateResponse(data interface{}) (interface{}, error) {
+	if err, ok := data.(error); ok {
+		return err.Error(), nil
+	}
+	state := &responseState{
+		options:  options,
+		visiting: make(map[visitKey]bool),
+	}
+	if source, ok := data.(ProfileResponseValueCreator); ok && !isNilPointer(data) {
+		profile, err := state.profile(options.Joins)
+		if err != nil {
+			return nil, err
+		}
+		data = source.ProfileResponseValue(profile, options.Joins)
+	}
+	return createResponse(data, false, options.Joins, state)
+}
+
+// StreamResponse creates the response value for data the same as
+// CreateResponse, passing it to write.  Collections (slices and
+// arrays that don't have their own response value) are created one
+// element at a time, and each element is passed to write as soon as
+// it is created, so that the whole response never has to be held in
+// memory.  Other values are created whole; if their response value is
+// a list, its elements are passed to write one at a time.  If an
+// element can't be created, the elements before it have already been
+// written.
+func (options *ResponseOptions) StreamResponse(data interface{}, write func(interface{}) error) error {
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if !isStreamable(data, value) {
+		response, err := options.CreateResponse(data)
+		if err != nil {
+			return err
+		}
+		elements, ok := response.([]interface{})
+		if !ok {
+			return write(response)
+		}
+		for _, element := range elements {
+			if err := write(element); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	state := &responseState{
+		options:  options,
+		visiting: make(map[visitKey]bool),
+	}
+	for i := 0; i < value.Len(); i++ {
+		if err := state.push(strconv.Itoa(i), options.Joins); err != nil {
+			return err
+		}
+		element, err := createResponseValue(value.Index(i), options.Joins, state)
+		state.pop()
+		if err != nil {
+			return err
+		}
+		if err := write(element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStreamable returns whether or not data (with value as its
+// dereferenced value) is a plain collection that StreamResponse can
+// create one element at a time.
+func isStreamable(data interface{}, value reflect.Value) bool {
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return false
+	}
+	if _, _, ok := typeRenderer(reflect.ValueOf(data)); ok {
+		return false
+	}
+	switch data.(type) {
+	case LazyLoader, ResponseObjectCreator, ProfileResponseValueCreator, error:
+		return false
+	}
+	return true
+}
+
+// fieldSelected returns whether or not the field at path (a dot
+// separated list of keys) should be included in the response, based
+// on the Fields option.
+func (options *ResponseOptions) fieldSelected(path string) bool {
+	if len(options.Fields) == 0 {
+		return true
+	}
+	for _, field := range options.Fields {
+		if path == field ||
+			strings.HasPrefix(path, field+".") ||
+			strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package web_responders
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TagOptions are the comma separated options that follow the name in
+// a "response" or "json" struct tag.  For example, the options for
+// `response:"name,omitempty"` are []string{"omitempty"}.
+type TagOptions []string
+
+// Has returns whether or not option is one of the options.
+func (options TagOptions) Has(option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the value of a "key=value" option, e.g. the value of
+// "money" for the key "render" in `response:"price,render=money"`.
+func (options TagOptions) Value(key string) (string, bool) {
+	prefix := key + "="
+	for _, o := range options {
+		if strings.HasPrefix(o, prefix) {
+			return o[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
+// A NamingStrategy converts a field name to the key that will be
+// used for the field in a response.  It is only used for fields that
+// don't have a name in any of their tags.
+type NamingStrategy func(fieldName string) string
+
+// fieldNaming is the NamingStrategy used by ResponseTag.
+var fieldNaming NamingStrategy = LowerCase
+
+// SetNamingStrategy sets the NamingStrategy that will be used for
+// fields without tags.  The default is LowerCase.  This is not safe
+// to call while responses are being created, so it should be called
+// during startup.
+func SetNamingStrategy(strategy NamingStrategy) {
+	fieldNaming = strategy
+	resetStructPlans()
+}
+
+// LowerCase is a NamingStrategy that just lowercases the field name,
+// e.g. "PlayCount" becomes "playcount".
+func LowerCase(fieldName string) string {
+	return strings.ToLower(fieldName)
+}
+
+// SnakeCase is a NamingStrategy that separates words with
+// underscores, e.g. "PlayCount" becomes "play_count" and "SongID"
+// becomes "song_id".
+func SnakeCase(fieldName string) string {
+	return strings.Join(fieldWords(fieldName), "_")
+}
+
+// CamelCase is a NamingStrategy that lowercases the first word of the
+// field name, e.g. "PlayCount" becomes "playCount" and "ID" becomes
+// "id".
+func CamelCase(fieldName string) string {
+	words := fieldWords(fieldName)
+	for i := 1; i < len(words); i++ {
+		first, size := utf8.DecodeRuneInString(words[i])
+		words[i] = string(unicode.ToUpper(first)) + words[i][size:]
+	}
+	return strings.Join(words, "")
+}
+
+// fieldWords splits a field name into lowercase words.  A run of
+// capital letters is treated as an initialism, so "HTTPStatus" is
+// split into "http" and "status".
+func fieldWords(fieldName string) []string {
+	runes := []rune(fieldName)
+	words := []string{}
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		previous, current := runes[i-1], runes[i]
+		startsWord := unicode.IsUpper(current) &&
+			(unicode.IsLower(previous) || unicode.IsDigit(previous) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+		if startsWord || current == '_' {
+			if start < i {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+			}
+			start = i
+			if current == '_' {
+				start++
+			}
+		}
+	}
+	if start < len(runes) {
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+	return words
+}
+
+// ResponseTag returns the key that will be used for field in a
+// response.  See ParseResponseTag.
+func ResponseTag(field reflect.StructField) string {
+	name, _ := ParseResponseTag(field)
+	return name
+}
+
+// ParseResponseTag returns the key that will be used for field in a
+// response, along with any options from its tag.  The name is taken
+// from the first of these that is present:
+//
+// 1. The name in the "response" tag.
+// 2. The "db" tag, unless the field is named Id.
+// 3. The name in the "json" tag.
+// 4. The field name, converted using the current NamingStrategy.
+//
+// A name of "-" means that the field should be skipped.  Options are
+// taken from the "response" tag if it exists, and from the "json" tag
+// otherwise, so fields that are already tagged for encoding/json
+// (e.g. `json:"name,omitempty"`) behave the same way here.
+func ParseResponseTag(field reflect.StructField) (string, TagOptions) {
+	responseTag, hasResponseTag := field.Tag.Lookup("response")
+	responseName, responseOptions := splitTag(responseTag)
+	jsonName, jsonOptions := splitTag(field.Tag.Get("json"))
+
+	options := jsonOptions
+	if hasResponseTag {
+		options = responseOptions
+	}
+	if responseName != "" {
+		return responseName, options
+	}
+	if field.Name != "Id" {
+		if name := field.Tag.Get("db"); name != "" && name != "-" {
+			return name, options
+		}
+	}
+	if jsonName != "" {
+		if jsonName == "-" && len(jsonOptions) > 0 {
+			// encoding/json uses `json:"-,"` for a key that is
+			// literally "-"; we can't support that here, since "-"
+			// means skip, so fall back to the field name.
+			return fieldNaming(field.Name), options
+		}
+		return jsonName, options
+	}
+	return fieldNaming(field.Name), options
+}
+
+// splitTag splits a tag value into its name and options.
+func splitTag(tag string) (string, TagOptions) {
+	if tag == "" {
+		return "", nil
+	}
+	parts := strings.Split(tag, ",")
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return parts[0], TagOptions(parts[1:])
+}
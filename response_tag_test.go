@@ -0,0 +1,53 @@
+package web_responders
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func TestNamingStrategies(t *testing.T) {
+	cases := []struct {
+		field, snake, camel string
+	}{
+		{"Name", "name", "name"},
+		{"PlayCount", "play_count", "playCount"},
+		{"SongID", "song_id", "songId"},
+		{"HTTPStatus", "http_status", "httpStatus"},
+		{"Track2Title", "track2_title", "track2Title"},
+		{"SongÄrger", "song_ärger", "songÄrger"},
+		{"ÜberTitle", "über_title", "überTitle"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.snake, SnakeCase(c.field))
+		assert.Equal(t, c.camel, CamelCase(c.field))
+	}
+}
+
+func TestParseResponseTag(t *testing.T) {
+	type tagged struct {
+		Id       int    `db:"song_id"`
+		Title    string `db:"title" json:"name"`
+		Artist   string `json:"artist_name,omitempty"`
+		Plays    int    `response:",string" json:"plays,omitempty"`
+		Hidden   string `json:"-"`
+		Untagged string
+	}
+	structType := reflect.TypeOf(tagged{})
+	expected := []struct {
+		name    string
+		options TagOptions
+	}{
+		{"id", nil},
+		{"title", nil},
+		{"artist_name", TagOptions{"omitempty"}},
+		{"plays", TagOptions{"string"}},
+		{"-", nil},
+		{"untagged", nil},
+	}
+	for i, e := range expected {
+		name, options := ParseResponseTag(structType.Field(i))
+		assert.Equal(t, e.name, name)
+		assert.Equal(t, e.options, options)
+	}
+}
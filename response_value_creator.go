@@ -23,3 +23,17 @@ type ResponseValueCreator interface {
 	// represent the underlying value in a response.
 	ResponseValue(options objx.Map) interface{}
 }
+
+// An OptionsResponseValueCreator is a ResponseValueCreator that needs
+// to know more about the response it is a part of - for example, to
+// check the Principal before including private details, or to change
+// its representation based on the Version.  It is used in place of
+// ResponseValueCreator whenever a type implements both.
+type OptionsResponseValueCreator interface {
+
+	// ResponseValueWithOptions should return the value that will be
+	// used to represent the underlying value in a response.  The
+	// objx.Map is the same joins options that would be passed to
+	// ResponseValue.
+	ResponseValueWithOptions(joins objx.Map, options *ResponseOptions) interface{}
+}
@@ -0,0 +1,211 @@
+package web_responders
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/stretchr/goweb"
+	"github.com/stretchr/goweb/context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	eventStreamMimeType = "text/event-stream"
+	lastEventIdHeader   = "Last-Event-ID"
+
+	// lastEventIdParameter is used by EventSource polyfills that
+	// can't set headers.
+	lastEventIdParameter = "lastEventId"
+
+	// eventErrorMessage is sent in place of events whose data can't
+	// be created for reasons other than a ResponseError, which
+	// shouldn't be shown to clients.
+	eventErrorMessage = "The event could not be created"
+)
+
+var (
+	// EventCodecType is the content type of the codec used to
+	// create the data of each event sent by RespondWithEvents.  The
+	// default is the JSON version of our envelope, so that events
+	// look the same as regular responses.
+	EventCodecType = "application/vnd.radiobox.encapsulated+json"
+
+	// EventRetry is sent to clients as the time to wait before
+	// reconnecting after the connection is lost.  Zero means that
+	// the browser's default is used.
+	EventRetry = 3 * time.Second
+
+	// EventHeartbeat is how often a comment is sent when there are
+	// no events, so that proxies don't close idle connections.  Zero
+	// means that no heartbeats are sent.
+	EventHeartbeat = 15 * time.Second
+)
+
+// An Event is an update sent to clients by RespondWithEvents.
+type Event struct {
+	// Id identifies the event, so that clients that reconnect can
+	// resume after the last event they received (see LastEventId).
+	Id string
+
+	// Name is the type of the event, which browsers use to choose an
+	// event listener.  An empty name means "message".
+	Name string
+
+	// Notifications and Data are the same as the notifications and
+	// data passed to Respond.
+	Notifications MessageMap
+	Data          interface{}
+}
+
+// LastEventId returns the id of the last event received by a client
+// that is reconnecting, or an empty string for new clients.
+// Controllers use this to choose the events to send, e.g. sending any
+// events that the client missed before waiting for new ones.
+func LastEventId(ctx context.Context) string {
+	if id := ctx.HttpRequest().Header.Get(lastEventIdHeader); id != "" {
+		return id
+	}
+	return ctx.QueryValue(lastEventIdParameter)
+}
+
+// RespondWithEvents responds with a stream of Server-Sent Events,
+// keeping the connection open and sending each event from events
+// until events is closed or the client disconnects.
+//
+// The data of each event is created by the codec for EventCodecType,
+// using the same options as Respond (joins, fields, domain, locale,
+// and so on), so each event contains the same envelope as a regular
+// 200 OK response would.  If an event's data can't be created, the
+// event will contain an error response instead, since the stream has
+// already started and its status can't be changed.  Events are never
+// changed, so the same event may be sent to many clients at once.
+// Nil events are skipped.
+func RespondWithEvents(ctx context.Context, events <-chan *Event, useFullDomain ...bool) error {
+	writer := ctx.HttpResponseWriter()
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return errors.New("The response writer does not support streaming")
+	}
+	codec, err := goweb.CodecService.GetCodec(EventCodecType)
+	if err != nil {
+		return err
+	}
+	domain := ""
+	if len(useFullDomain) > 0 && useFullDomain[0] {
+		domain = RequestDomain(ctx.HttpRequest())
+	}
+	options, err := setCodecOptions(ctx, http.StatusOK, nil, domain)
+	if optionErr, ok := err.(*OptionError); ok {
+		return respondWithResponseError(ctx, nil, optionErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	header := writer.Header()
+	header.Set("Content-Type", eventStreamMimeType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	if EventRetry > 0 {
+		fmt.Fprintf(writer, "retry: %d\n\n", EventRetry/time.Millisecond)
+	}
+	flusher.Flush()
+
+	var heartbeat <-chan time.Time
+	if EventHeartbeat > 0 {
+		ticker := time.NewTicker(EventHeartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	closed := ctx.HttpRequest().Context().Done()
+	for {
+		select {
+		case <-closed:
+			return nil
+		case <-heartbeat:
+			if _, err := writer.Write([]byte(": heartbeat\n\n")); err != nil {
+				return err
+			}
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if event == nil {
+				continue
+			}
+			data, err := marshalEvent(codec.Marshal, options, event)
+			if err != nil {
+				return err
+			}
+			if _, err := writer.Write(data); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// marshalEvent returns event in the text/event-stream format, using
+// marshal (the Marshal method of a codec) to create its data.  The
+// event's notifications are translated to the "locale" in options
+// without changing the event.  If the data can't be created, the
+// event's data is an error response instead; an error is only
+// returned if that can't be created either.
+func marshalEvent(marshal func(interface{}, map[string]interface{}) ([]byte, error), options map[string]interface{}, event *Event) ([]byte, error) {
+	locale, _ := options["locale"].(string)
+	notifications := event.Notifications.localized(locale)
+	eventOptions := make(map[string]interface{}, len(options)+2)
+	for key, value := range options {
+		eventOptions[key] = value
+	}
+	eventOptions["status"] = http.StatusOK
+	eventOptions["notifications"] = notifications
+	data, err := marshal(event.Data, eventOptions)
+	if err != nil {
+		// The same as respondWithResponseError, except that other
+		// errors can't end the response with a 500 Internal Server
+		// Error, since the stream's headers have already been sent.
+		status := http.StatusInternalServerError
+		message := eventErrorMessage
+		if responseErr, ok := err.(ResponseError); ok {
+			status = responseErr.ResponseStatus()
+			message = responseErr.Error()
+		} else {
+			log.Print("ERR: could not create event: ", err)
+		}
+		if notifications == nil {
+			notifications = NewMessageMap()
+		}
+		notifications.AddErrorMessage(message)
+		eventOptions["status"] = status
+		eventOptions["notifications"] = notifications
+		eventOptions["joins"] = ""
+		data, err = marshal(nil, eventOptions)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := new(bytes.Buffer)
+	if event.Id != "" {
+		fmt.Fprintf(buffer, "id: %s\n", eventField(event.Id))
+	}
+	if event.Name != "" {
+		fmt.Fprintf(buffer, "event: %s\n", eventField(event.Name))
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(buffer, "data: %s\n", strings.TrimSuffix(line, "\r"))
+	}
+	buffer.WriteString("\n")
+	return buffer.Bytes(), nil
+}
+
+// eventField removes line breaks from a value, since they would end
+// the field early.
+func eventField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,107 @@
+package web_responders
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/goweb"
+	"github.com/stretchr/goweb/webcontext"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testEventMarshal creates a two line envelope, to check that each
+// line gets its own data field.
+func testEventMarshal(object interface{}, options map[string]interface{}) ([]byte, error) {
+	response := CreateResponse(object)
+	meta, _ := json.Marshal(map[string]interface{}{"code": options["status"]})
+	data, _ := json.Marshal(response)
+	return append(append(meta, '\n'), data...), nil
+}
+
+func TestMarshalEvent(t *testing.T) {
+	event := &Event{
+		Id:   "12\n",
+		Name: "update",
+		Data: map[string]interface{}{"title": "New"},
+	}
+	data, err := marshalEvent(testEventMarshal, map[string]interface{}{"status": http.StatusCreated}, event)
+	assert.NoError(t, err)
+	assert.Equal(t, "id: 12\nevent: update\ndata: {\"code\":200}\ndata: {\"title\":\"New\"}\n\n", string(data))
+
+	data, err = marshalEvent(testEventMarshal, nil, &Event{})
+	assert.NoError(t, err)
+	assert.Equal(t, "data: {\"code\":200}\ndata: null\n\n", string(data))
+}
+
+func TestMarshalEventResponseError(t *testing.T) {
+	var received map[string]interface{}
+	marshal := func(object interface{}, options map[string]interface{}) ([]byte, error) {
+		if object != nil {
+			return nil, &OptionError{Message: "Bad joins"}
+		}
+		received = options
+		return []byte("{}"), nil
+	}
+	notifications := NewMessageMap()
+	event := &Event{Notifications: notifications, Data: "value"}
+	data, err := marshalEvent(marshal, nil, event)
+	assert.NoError(t, err)
+	assert.Equal(t, "data: {}\n\n", string(data))
+	assert.Equal(t, http.StatusBadRequest, received["status"])
+	assert.Equal(t, []string{"Invalid options for the response: Bad joins"}, received["notifications"].(MessageMap).Errors())
+	assert.Equal(t, 0, notifications.NumErrors(), "the event's own notifications must not change")
+}
+
+func TestMarshalEventOtherError(t *testing.T) {
+	var received map[string]interface{}
+	marshal := func(object interface{}, options map[string]interface{}) ([]byte, error) {
+		if object != nil {
+			return nil, errors.New("unsupported value")
+		}
+		received = options
+		return []byte("{}"), nil
+	}
+	data, err := marshalEvent(marshal, nil, &Event{Id: "3", Data: "value"})
+	assert.NoError(t, err)
+	assert.Equal(t, "id: 3\ndata: {}\n\n", string(data))
+	assert.Equal(t, http.StatusInternalServerError, received["status"])
+	assert.Equal(t, []string{eventErrorMessage}, received["notifications"].(MessageMap).Errors())
+}
+
+func TestRespondWithEvents(t *testing.T) {
+	defer func(codecType string, retry, heartbeat time.Duration) {
+		EventCodecType, EventRetry, EventHeartbeat = codecType, retry, heartbeat
+	}(EventCodecType, EventRetry, EventHeartbeat)
+	EventCodecType = "application/json"
+	EventRetry = 0
+	EventHeartbeat = 0
+
+	// The same event is sent to several clients at once, each of
+	// which localizes its notifications.
+	notifications := NewMessageMap()
+	notifications.AddInfoMessage(Localize(MessageMissingInput))
+	event := &Event{Id: "1", Notifications: notifications, Data: map[string]interface{}{"title": "New"}}
+
+	var wait sync.WaitGroup
+	for client := 0; client < 4; client++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			request, _ := http.NewRequest("GET", "http://example.com/events", nil)
+			recorder := httptest.NewRecorder()
+			events := make(chan *Event, 3)
+			events <- nil
+			events <- event
+			close(events)
+			assert.NoError(t, RespondWithEvents(webcontext.NewWebContext(recorder, request, goweb.CodecService), events))
+			assert.Equal(t, eventStreamMimeType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, "id: 1\ndata: {\"title\":\"New\"}\n\n", recorder.Body.String())
+		}()
+	}
+	wait.Wait()
+	assert.Equal(t, []string{"No input for required field"}, notifications.Infos())
+}
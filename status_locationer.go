@@ -0,0 +1,9 @@
+package web_responders
+
+// A StatusLocationer is a type that can return the location of a
+// status monitor for itself, relative to the server root path.  It is
+// used as the Location of 202 Accepted responses, so that clients can
+// check on requests that are still being processed.
+type StatusLocationer interface {
+	StatusLocation() string
+}
@@ -0,0 +1,62 @@
+package web_responders
+
+import (
+	"github.com/stretchr/goweb/context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SuppressStatusParameter and SuppressStatusHeader are the query
+	// parameter and header that clients use to ask for status
+	// suppression (see StatusSuppressed).
+	SuppressStatusParameter = "suppress_response_codes"
+	SuppressStatusHeader    = "X-Suppress-Response-Codes"
+
+	// StatusSuppressedOption is the codec option that is set to true
+	// when the status of a response is suppressed, so that codecs can
+	// record it in the response.
+	StatusSuppressedOption = "status_suppressed"
+)
+
+// StatusSuppressed returns whether or not the client asked for the
+// status of responses to be suppressed, by setting the
+// SuppressStatusParameter query parameter or the SuppressStatusHeader
+// header to a true value (e.g. "true" or "1").  Some of our embedded
+// and set-top clients can't read the body of a response unless its
+// status is 200 OK, so suppressed responses are always sent as a 200
+// OK, and the real status is only in the envelope's meta.  Since the
+// header changes the response, Respond adds it to the Vary header of
+// every response.
+func StatusSuppressed(ctx context.Context) bool {
+	value := ctx.HttpRequest().Header.Get(SuppressStatusHeader)
+	if value == "" {
+		value = ctx.QueryValue(SuppressStatusParameter)
+	}
+	suppressed, _ := strconv.ParseBool(value)
+	return suppressed
+}
+
+// addVary adds name to the Vary header, keeping any names that are
+// already there.
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
+
+// writtenStatus returns the status to write for a response with
+// status, which is 200 OK if the status is suppressed.
+func writtenStatus(ctx context.Context, status int) int {
+	if suppressed, _ := ctx.CodecOptions()[StatusSuppressedOption].(bool); suppressed {
+		return http.StatusOK
+	}
+	return status
+}
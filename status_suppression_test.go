@@ -0,0 +1,46 @@
+package web_responders
+
+import (
+	"github.com/stretchr/goweb"
+	"github.com/stretchr/goweb/webcontext"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testRequiredInput struct {
+	Title string `request:"title"`
+}
+
+func TestRespondWithInputErrorsSuppressed(t *testing.T) {
+	request, _ := http.NewRequest("POST", "http://example.com/songs", nil)
+	request.Header.Set(SuppressStatusHeader, "true")
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Vary", "Accept")
+	ctx := webcontext.NewWebContext(recorder, request, goweb.CodecService)
+
+	notifications := NewMessageMap()
+	assert.NoError(t, RespondWithInputErrors(ctx, notifications, &testRequiredInput{}, true))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, true, ctx.CodecOptions()[StatusSuppressedOption])
+	assert.Equal(t, http.StatusBadRequest, ctx.CodecOptions()["status"])
+	assert.Contains(t, notifications.InputMessages(), "title")
+	assert.Equal(t, []string{"Accept", SuppressStatusHeader}, recorder.Header().Values("Vary"))
+
+	// The controller's Vary header doesn't replace it, either.
+	assert.NoError(t, new(BaseRestController).After(ctx))
+	assert.Equal(t, []string{"Accept", SuppressStatusHeader}, recorder.Header().Values("Vary"))
+}
+
+func TestAddVary(t *testing.T) {
+	header := http.Header{}
+	addVary(header, "Accept")
+	addVary(header, "accept")
+	addVary(header, SuppressStatusHeader)
+	assert.Equal(t, []string{"Accept", SuppressStatusHeader}, header.Values("Vary"))
+
+	header = http.Header{"Vary": {"*"}}
+	addVary(header, "Accept")
+	assert.Equal(t, []string{"*"}, header.Values("Vary"))
+}
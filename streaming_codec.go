@@ -0,0 +1,65 @@
+package web_responders
+
+import (
+	"github.com/stretchr/goweb/context"
+	"io"
+	"net/http"
+)
+
+// A StreamingCodec is a codec that can write a response as it is
+// created, rather than creating the whole response in memory first
+// (for example, newline delimited JSON).  When the codec chosen for a
+// response is a StreamingCodec, Respond writes the response with
+// Encode instead of Marshal.
+type StreamingCodec interface {
+	ContentType() string
+	Encode(w io.Writer, object interface{}, options map[string]interface{}) error
+}
+
+// respondWithStream writes data to the client using codec.Encode.
+// The status line isn't written until Encode writes something, so an
+// error before then (e.g. malformed joins) still gets a regular error
+// response.  Once the output has started, an error can only end it
+// early.
+func respondWithStream(ctx context.Context, codec StreamingCodec, status int, notifications MessageMap, data interface{}) error {
+	writer := &streamWriter{ResponseWriter: ctx.HttpResponseWriter(), status: writtenStatus(ctx, status)}
+	writer.Header().Set("Content-Type", codec.ContentType())
+	err := codec.Encode(writer, data, ctx.CodecOptions())
+	if err == nil || writer.started {
+		return err
+	}
+	writer.Header().Del("Content-Type")
+	if responseErr, ok := err.(ResponseError); ok {
+		return respondWithResponseError(ctx, notifications, responseErr)
+	}
+	return err
+}
+
+// streamWriter is an http.ResponseWriter that writes its status the
+// first time anything is written or flushed.
+type streamWriter struct {
+	http.ResponseWriter
+	status  int
+	started bool
+}
+
+func (writer *streamWriter) start() {
+	if !writer.started {
+		writer.started = true
+		writer.ResponseWriter.WriteHeader(writer.status)
+	}
+}
+
+func (writer *streamWriter) Write(data []byte) (int, error) {
+	writer.start()
+	return writer.ResponseWriter.Write(data)
+}
+
+// Flush sends any buffered output to the client, if the underlying
+// http.ResponseWriter supports it.
+func (writer *streamWriter) Flush() {
+	writer.start()
+	if flusher, ok := writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,86 @@
+package web_responders
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/goweb"
+	"github.com/stretchr/goweb/webcontext"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testStreamCodec writes each element of a []string on its own line,
+// flushing after each one.
+type testStreamCodec struct{}
+
+func (codec testStreamCodec) Marshal(object interface{}, options map[string]interface{}) ([]byte, error) {
+	return []byte(fmt.Sprint(options["status"])), nil
+}
+
+func (codec testStreamCodec) Unmarshal(data []byte, obj interface{}) error {
+	return errors.New("Unmarshal not supported")
+}
+
+func (codec testStreamCodec) ContentType() string {
+	return "application/vnd.test-stream"
+}
+
+func (codec testStreamCodec) FileExtension() string {
+	return ".stream"
+}
+
+func (codec testStreamCodec) CanMarshalWithCallback() bool {
+	return false
+}
+
+func (codec testStreamCodec) Encode(w io.Writer, object interface{}, options map[string]interface{}) error {
+	if options["joins"] == "bad" {
+		return &OptionError{Message: "bad joins"}
+	}
+	flusher := w.(http.Flusher)
+	for _, line := range object.([]string) {
+		if line == "fail" {
+			return errors.New("could not create line")
+		}
+		fmt.Fprintln(w, line)
+		flusher.Flush()
+	}
+	return nil
+}
+
+func respondWithTestStream(joins string, data []string) (*httptest.ResponseRecorder, error) {
+	request, _ := http.NewRequest("GET", "http://example.com/songs", nil)
+	request.Header.Set("Accept", testStreamCodec{}.ContentType())
+	recorder := httptest.NewRecorder()
+	ctx := webcontext.NewWebContext(recorder, request, goweb.CodecService)
+	if joins != "" {
+		ctx.CodecOptions().Set("joins", joins)
+	}
+	return recorder, Respond(ctx, http.StatusOK, nil, data)
+}
+
+func TestRespondWithStreamingCodec(t *testing.T) {
+	goweb.CodecService.AddCodec(testStreamCodec{})
+
+	recorder, err := respondWithTestStream("", []string{"one", "two"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, "application/vnd.test-stream", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "one\ntwo\n", recorder.Body.String())
+
+	// Errors before anything is written get a regular error response.
+	recorder, err = respondWithTestStream("bad", []string{"one"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "400", recorder.Body.String())
+
+	// Later errors can only end the output early.
+	recorder, err = respondWithTestStream("", []string{"one", "fail"})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "one\n", recorder.Body.String())
+}
@@ -0,0 +1,324 @@
+package web_responders
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unicode"
+)
+
+var (
+	lazyLoaderType                  = reflect.TypeOf((*LazyLoader)(nil)).Elem()
+	responseObjectCreatorType       = reflect.TypeOf((*ResponseObjectCreator)(nil)).Elem()
+	responseValueCreatorType        = reflect.TypeOf((*ResponseValueCreator)(nil)).Elem()
+	optionsResponseValueCreatorType = reflect.TypeOf((*OptionsResponseValueCreator)(nil)).Elem()
+	profileResponseValueCreatorType = reflect.TypeOf((*ProfileResponseValueCreator)(nil)).Elem()
+	stringerType                    = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	errorType                       = reflect.TypeOf((*error)(nil)).Elem()
+	isZeroerType                    = reflect.TypeOf((*IsZeroer)(nil)).Elem()
+	urlType                         = reflect.TypeOf(URL(""))
+)
+
+// A fieldPlan holds everything createStructResponse needs to know
+// about a single struct field.
+type fieldPlan struct {
+	// index is the index sequence of the field, for use with
+	// reflect.Value.FieldByIndex.
+	index []int
+
+	// name is the key that the field's value will be stored under.
+	name string
+
+	// embedded is true for anonymous fields that could not be
+	// flattened into the parent's plan, and need to be rendered on
+	// their own and then merged into the parent's response.
+	embedded bool
+
+	// promoted is true for fields that were flattened into the plan
+	// from an embedded struct.  Promoted fields never overwrite a
+	// value that already exists in the response.
+	promoted bool
+
+	// scalar is true for fields with a basic kind (bool, numbers, and
+	// strings) that don't implement any of the interfaces we check
+	// for, meaning their value can be used directly.
+	scalar bool
+
+	// asString is true for fields with the "string" tag option.
+	asString bool
+
+	// link is true for fields with the "link" tag option, which have
+	// the domain prepended to their relative URLs.
+	link bool
+
+	// renderer is the Renderer named in the "render" tag option, if
+	// any.
+	renderer Renderer
+
+	// omitEmpty is true for fields with the "omitempty" tag option,
+	// and omitNil is true for fields with the "omitnil" tag option.
+	omitEmpty bool
+	omitNil   bool
+}
+
+// A structPlan is the compiled version of a struct type, as far as
+// createStructResponse is concerned.
+type structPlan struct {
+	// nullable is true for "database/sql".Null* types and any other
+	// types matching that structure.  The nullValue and nullValid
+	// fields will contain the field indexes of the value and the
+	// Valid flag.
+	nullable  bool
+	nullValue int
+	nullValid int
+
+	fields []fieldPlan
+}
+
+// structPlans is the cache of compiled struct types.
+var structPlans = struct {
+	sync.RWMutex
+	plans map[reflect.Type]*structPlan
+}{plans: make(map[reflect.Type]*structPlan)}
+
+// structPlanFor returns the compiled plan for structType, compiling
+// (and caching) it if this is the first time we've seen the type.
+// Plans that fail to compile aren't cached, so that the error is
+// returned every time (or goes away, once a missing renderer has been
+// registered).
+func structPlanFor(structType reflect.Type) (*structPlan, error) {
+	structPlans.RLock()
+	plan, ok := structPlans.plans[structType]
+	structPlans.RUnlock()
+	if ok {
+		return plan, nil
+	}
+	plan, err := compileStructPlan(structType)
+	if err != nil {
+		return nil, err
+	}
+	structPlans.Lock()
+	structPlans.plans[structType] = plan
+	structPlans.Unlock()
+	return plan, nil
+}
+
+// resetStructPlans clears the plan cache.  This needs to happen any
+// time something changes the way fields are named.
+func resetStructPlans() {
+	structPlans.Lock()
+	structPlans.plans = make(map[reflect.Type]*structPlan)
+	structPlans.Unlock()
+}
+
+// compileStructPlan walks the fields of structType to create a
+// structPlan.  A *RendererError is returned if a field's "render" tag
+// option names a renderer that hasn't been registered; its Path is
+// the key of the field within the struct.
+func compileStructPlan(structType reflect.Type) (*structPlan, error) {
+	plan := new(structPlan)
+	if valueIndex, validIndex, ok := nullableFields(structType); ok {
+		plan.nullable = true
+		plan.nullValue = valueIndex
+		plan.nullValid = validIndex
+		return plan, nil
+	}
+
+	// Fields of the struct itself always take precedence over fields
+	// promoted from embedded structs, so we need to know their names
+	// before flattening anything.
+	direct := make(map[string]bool)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.Anonymous && unicode.IsUpper(rune(field.Name[0])) {
+			direct[ResponseTag(field)] = true
+		}
+	}
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Anonymous {
+			name := ResponseTag(field)
+			if name == "-" {
+				continue
+			}
+			if !canFlatten(field.Type) {
+				plan.fields = append(plan.fields, fieldPlan{index: []int{i}, name: name, embedded: true})
+				continue
+			}
+			embeddedPlan, err := structPlanFor(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			for _, promoted := range embeddedPlan.fields {
+				if !promoted.embedded && direct[promoted.name] {
+					continue
+				}
+				promoted.index = append([]int{i}, promoted.index...)
+				promoted.promoted = true
+				plan.fields = append(plan.fields, promoted)
+			}
+			continue
+		}
+		if !unicode.IsUpper(rune(field.Name[0])) {
+			continue
+		}
+		name, tagOptions := ParseResponseTag(field)
+		if name == "-" {
+			continue
+		}
+		var renderer Renderer
+		if rendererName, ok := tagOptions.Value("render"); ok {
+			renderer, ok = namedRenderers[rendererName]
+			if !ok {
+				return nil, &RendererError{Path: name, Name: rendererName}
+			}
+		}
+		plan.fields = append(plan.fields, fieldPlan{
+			index:     []int{i},
+			name:      name,
+			scalar:    isScalar(field.Type),
+			asString:  tagOptions.Has("string"),
+			link:      tagOptions.Has("link"),
+			renderer:  renderer,
+			omitEmpty: tagOptions.Has("omitempty"),
+			omitNil:   tagOptions.Has("omitnil"),
+		})
+	}
+	return plan, nil
+}
+
+// nullableFields checks for "database/sql".Null* types (including the
+// generic Null[T]), or anything with a similar structure, and returns
+// the index of the value field and the Valid field.
+func nullableFields(structType reflect.Type) (valueIndex, validIndex int, ok bool) {
+	typeName := structType.Name()
+	if len(typeName) <= len(SqlNullablePrefix) || typeName[:len(SqlNullablePrefix)] != SqlNullablePrefix {
+		return 0, 0, false
+	}
+	valueName := typeName[len(SqlNullablePrefix):]
+	if valueName[0] == '[' {
+		// The generic "database/sql".Null[T] type, e.g. Null[int].
+		valueName = "V"
+	}
+	valueField, hasValue := structType.FieldByName(valueName)
+	validField, hasValid := structType.FieldByName("Valid")
+	if !hasValue || !hasValid || len(valueField.Index) != 1 || len(validField.Index) != 1 || validField.Type.Kind() != reflect.Bool {
+		return 0, 0, false
+	}
+	return valueField.Index[0], validField.Index[0], true
+}
+
+// canFlatten returns whether or not an embedded type can have its
+// fields flattened into its parent's plan.  Types that CreateResponse
+// would handle specially (e.g. LazyLoaders, or nullable types) have
+// to be rendered on their own.
+func canFlatten(embeddedType reflect.Type) bool {
+	if embeddedType.Kind() != reflect.Struct {
+		return false
+	}
+	if embeddedType.Implements(lazyLoaderType) ||
+		embeddedType.Implements(responseObjectCreatorType) ||
+		embeddedType.Implements(errorType) ||
+		embeddedType.Implements(nullableType) ||
+		embeddedType.Implements(valuerType) ||
+		hasTypeRenderer(embeddedType) {
+		return false
+	}
+	_, _, nullable := nullableFields(embeddedType)
+	return !nullable
+}
+
+// isScalar returns whether or not values of fieldType can be used in
+// a response as-is.
+func isScalar(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return false
+	}
+	if fieldType == urlType || hasTypeRenderer(fieldType) {
+		return false
+	}
+	for _, iface := range []reflect.Type{responseValueCreatorType, optionsResponseValueCreatorType, profileResponseValueCreatorType, stringerType, errorType, lazyLoaderType, responseObjectCreatorType, nullableType, valuerType} {
+		if fieldType.Implements(iface) || reflect.PtrTo(fieldType).Implements(iface) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmptyValue returns whether or not value is empty, for the
+// "omitempty" tag option.  For the most part, this uses the same
+// rules as encoding/json: false, 0, nil pointers and interfaces, and
+// empty arrays, slices, maps and strings are all empty.  In addition:
+//
+// 1. Values that implement IsZeroer are empty when IsZero returns
+// true.
+//
+// 2. Nullable values (see Nullable and createStructResponse) are
+// empty when they are null, or when their underlying value is empty.
+//
+// Note that a nil pointer is empty even if it implements
+// NilResponder; use the "omitnil" tag option to only skip fields that
+// end up with a nil response value.
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if value.IsNil() {
+			return true
+		}
+	}
+	if zeroer, ok := asIsZeroer(value); ok {
+		return zeroer.IsZero()
+	}
+	if value.CanInterface() {
+		if nullValue, ok := nullableValue(value.Interface()); ok {
+			return nullValue == nil || isEmptyValue(reflect.ValueOf(nullValue))
+		}
+	}
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Struct:
+		plan, err := structPlanFor(value.Type())
+		if err == nil && plan.nullable {
+			return !value.Field(plan.nullValid).Bool() || isEmptyValue(value.Field(plan.nullValue))
+		}
+	}
+	return false
+}
+
+// asIsZeroer returns value as an IsZeroer, if either value or a
+// pointer to value implements IsZeroer.  Values that can't be
+// addressed (e.g. a struct passed by value, or a map element) are
+// copied, so that IsZero methods with pointer receivers are used no
+// matter how the value was passed.
+func asIsZeroer(value reflect.Value) (IsZeroer, bool) {
+	if !value.CanInterface() {
+		return nil, false
+	}
+	if value.Type().Implements(isZeroerType) {
+		return value.Interface().(IsZeroer), true
+	}
+	if !reflect.PtrTo(value.Type()).Implements(isZeroerType) {
+		return nil, false
+	}
+	if !value.CanAddr() {
+		addressable := reflect.New(value.Type()).Elem()
+		addressable.Set(value)
+		value = addressable
+	}
+	return value.Addr().Interface().(IsZeroer), true
+}
@@ -0,0 +1,125 @@
+package web_responders
+
+import (
+	"reflect"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// A TimeFormat converts a time.Time to the value used for it in a
+// response.
+type TimeFormat func(time.Time) interface{}
+
+// RFC3339 is a TimeFormat that formats times as RFC 3339 strings,
+// including fractional seconds when they are present.
+func RFC3339(t time.Time) interface{} {
+	return t.Format(time.RFC3339Nano)
+}
+
+// UnixSeconds is a TimeFormat that converts times to the number of
+// seconds since the Unix epoch.
+func UnixSeconds(t time.Time) interface{} {
+	return t.Unix()
+}
+
+// UnixMillis is a TimeFormat that converts times to the number of
+// milliseconds since the Unix epoch.
+func UnixMillis(t time.Time) interface{} {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// A DurationFormat converts a time.Duration to the value used for it
+// in a response.
+type DurationFormat func(time.Duration) interface{}
+
+// DurationString is a DurationFormat that uses the duration's String
+// method, e.g. "1m30s".
+func DurationString(d time.Duration) interface{} {
+	return d.String()
+}
+
+// DurationSeconds is a DurationFormat that converts durations to a
+// (possibly fractional) number of seconds.
+func DurationSeconds(d time.Duration) interface{} {
+	return d.Seconds()
+}
+
+// DurationMillis is a DurationFormat that converts durations to a
+// whole number of milliseconds.
+func DurationMillis(d time.Duration) interface{} {
+	return int64(d / time.Millisecond)
+}
+
+// TimeFormats maps the names that can be used to choose a TimeFormat
+// per request (e.g. with the time_format query parameter) to their
+// TimeFormat.
+var TimeFormats = map[string]TimeFormat{
+	"rfc3339": RFC3339,
+	"unix":    UnixSeconds,
+	"unix_ms": UnixMillis,
+}
+
+// DurationFormats maps the names that can be used to choose a
+// DurationFormat per request (e.g. with the duration_format query
+// parameter) to their DurationFormat.
+var DurationFormats = map[string]DurationFormat{
+	"string":  DurationString,
+	"seconds": DurationSeconds,
+	"ms":      DurationMillis,
+}
+
+var (
+	// DefaultTimeFormat is the TimeFormat set by
+	// NewResponseOptions.
+	DefaultTimeFormat TimeFormat = RFC3339
+
+	// DefaultTimeLocation is the location that times are converted
+	// to before being formatted, set by NewResponseOptions.  A nil
+	// location leaves times in their original location.
+	DefaultTimeLocation = time.UTC
+
+	// DefaultDurationFormat is the DurationFormat set by
+	// NewResponseOptions.
+	DefaultDurationFormat DurationFormat = DurationString
+)
+
+// formatTime returns the response value for value if it is a
+// time.Time or time.Duration (or a pointer to one).
+func (state *responseState) formatTime(value reflect.Value) (interface{}, bool) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, false
+		}
+		value = value.Elem()
+	}
+	switch value.Type() {
+	case timeType:
+		return state.options.formatTime(value.Interface().(time.Time)), true
+	case durationType:
+		return state.options.formatDuration(time.Duration(value.Int())), true
+	}
+	return nil, false
+}
+
+func (options *ResponseOptions) formatTime(t time.Time) interface{} {
+	if options.TimeLocation != nil {
+		t = t.In(options.TimeLocation)
+	}
+	format := options.TimeFormat
+	if format == nil {
+		format = RFC3339
+	}
+	return format(t)
+}
+
+func (options *ResponseOptions) formatDuration(d time.Duration) interface{} {
+	format := options.DurationFormat
+	if format == nil {
+		format = DurationString
+	}
+	return format(d)
+}
@@ -0,0 +1,32 @@
+package web_responders
+
+// A URL is a link that may be relative to the server root path (e.g.
+// "/songs/12").  Unlike plain strings, URL values in a response have
+// the domain (see ResponseOptions.Domain) prepended to them when they
+// are relative.  Plain string fields can be treated the same way with
+// the "link" tag option, e.g. `response:"artwork,link"`.
+type URL string
+
+// Absolute returns the url with domain prepended to it, if it is
+// relative to the server root path.
+func (url URL) Absolute(domain string) string {
+	if isRootRelative(string(url)) {
+		return domain + string(url)
+	}
+	return string(url)
+}
+
+// linkValue prepends the domain to responseValue, for fields with the
+// "link" tag option.  Strings and collections of strings are
+// supported; anything else is returned unchanged.
+func (state *responseState) linkValue(responseValue interface{}) interface{} {
+	switch src := responseValue.(type) {
+	case string:
+		return URL(src).Absolute(state.options.Domain)
+	case []interface{}:
+		for i, element := range src {
+			src[i] = state.linkValue(element)
+		}
+	}
+	return responseValue
+}